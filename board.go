@@ -0,0 +1,300 @@
+package rpio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type BoardModel uint8
+type SoC uint8
+type Header uint8
+
+// Board models, values match the type field of new-style revision codes
+const (
+	ModelA       BoardModel = 0x00
+	ModelB       BoardModel = 0x01
+	ModelAPlus   BoardModel = 0x02
+	ModelBPlus   BoardModel = 0x03
+	Model2B      BoardModel = 0x04
+	ModelAlpha   BoardModel = 0x05
+	ModelCM1     BoardModel = 0x06
+	Model3B      BoardModel = 0x08
+	ModelZero    BoardModel = 0x09
+	ModelCM3     BoardModel = 0x0a
+	ModelZeroW   BoardModel = 0x0c
+	Model3BPlus  BoardModel = 0x0d
+	Model3APlus  BoardModel = 0x0e
+	ModelCM3Plus BoardModel = 0x10
+	Model4B      BoardModel = 0x11
+	ModelZero2W  BoardModel = 0x12
+	Model400     BoardModel = 0x13
+	ModelCM4     BoardModel = 0x14
+	ModelCM4S    BoardModel = 0x15
+	Model5       BoardModel = 0x17
+	ModelCM5     BoardModel = 0x18
+	Model500     BoardModel = 0x19
+	ModelCM5Lite BoardModel = 0x1a
+
+	ModelUnknown BoardModel = 0xff
+)
+
+// SoC (processor) used on the board
+const (
+	BCM2835 SoC = iota
+	BCM2836
+	BCM2837
+	BCM2711
+	BCM2712
+
+	SoCUnknown SoC = 0xff
+)
+
+// GPIO header type, see the pinout tables in the package doc
+const (
+	NoHeader     Header = iota // compute modules, pins are on the SODIMM connector
+	Header26Rev1               // original model B, 26 pins
+	Header26Rev2               // model A and B rev 2, 26 pins
+	Header40                   // B+ and everything after, 40 pins
+)
+
+var modelNames = map[BoardModel]string{
+	ModelA:       "A",
+	ModelB:       "B",
+	ModelAPlus:   "A+",
+	ModelBPlus:   "B+",
+	Model2B:      "2B",
+	ModelAlpha:   "Alpha",
+	ModelCM1:     "CM1",
+	Model3B:      "3B",
+	ModelZero:    "Zero",
+	ModelCM3:     "CM3",
+	ModelZeroW:   "Zero W",
+	Model3BPlus:  "3B+",
+	Model3APlus:  "3A+",
+	ModelCM3Plus: "CM3+",
+	Model4B:      "4B",
+	ModelZero2W:  "Zero 2 W",
+	Model400:     "400",
+	ModelCM4:     "CM4",
+	ModelCM4S:    "CM4S",
+	Model5:       "5",
+	ModelCM5:     "CM5",
+	Model500:     "500",
+	ModelCM5Lite: "CM5 Lite",
+}
+
+func (model BoardModel) String() string {
+	if name, ok := modelNames[model]; ok {
+		return name
+	}
+	return "unknown"
+}
+
+func (soc SoC) String() string {
+	switch soc {
+	case BCM2835:
+		return "BCM2835"
+	case BCM2836:
+		return "BCM2836"
+	case BCM2837:
+		return "BCM2837"
+	case BCM2711:
+		return "BCM2711"
+	case BCM2712:
+		return "BCM2712"
+	default:
+		return "unknown"
+	}
+}
+
+func (header Header) String() string {
+	switch header {
+	case Header26Rev1:
+		return "26 pin (rev 1)"
+	case Header26Rev2:
+		return "26 pin (rev 2)"
+	case Header40:
+		return "40 pin"
+	default:
+		return "none"
+	}
+}
+
+// BoardInfo describes a Raspberry Pi board as decoded from its revision code
+type BoardInfo struct {
+	Revision     uint32 // raw revision code
+	Model        BoardModel
+	PCBRevision  string // eg. "1.2"
+	SoC          SoC
+	MemoryMB     int
+	Manufacturer string
+	Header       Header
+}
+
+func (b BoardInfo) String() string {
+	return fmt.Sprintf("Raspberry Pi %s rev %s (%s, %dMB, %s)", b.Model, b.PCBRevision, b.SoC, b.MemoryMB, b.Manufacturer)
+}
+
+var (
+	boardOnce sync.Once
+	boardInfo BoardInfo
+	boardErr  error
+)
+
+// Board returns information about the board the program is running on.
+//
+// The revision code is read from /proc/device-tree/system/linux,revision,
+// falling back to the Revision line of /proc/cpuinfo.
+// The result is detected once and cached, Open does not have to be called first.
+func Board() (BoardInfo, error) {
+	boardOnce.Do(func() {
+		var code uint32
+		code, boardErr = readRevision()
+		if boardErr != nil {
+			return
+		}
+		boardInfo, boardErr = DecodeRevision(code)
+	})
+	return boardInfo, boardErr
+}
+
+// ParseRevision decodes revision code in hexadecimal form,
+// as found in /proc/cpuinfo (eg. "a02082" or "000e").
+func ParseRevision(rev string) (BoardInfo, error) {
+	rev = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(rev)), "0x")
+	code, err := strconv.ParseUint(rev, 16, 32)
+	if err != nil {
+		return BoardInfo{}, fmt.Errorf("rpio: invalid revision code %q", rev)
+	}
+	return DecodeRevision(uint32(code))
+}
+
+// DecodeRevision decodes both old-style and new-style revision codes, see
+// https://www.raspberrypi.com/documentation/computers/raspberry-pi.html#raspberry-pi-revision-codes
+func DecodeRevision(code uint32) (BoardInfo, error) {
+	const newStyle = 1 << 23
+
+	if code&newStyle == 0 {
+		return decodeOldRevision(code)
+	}
+
+	// NOQuuuWuFMMMCCCCPPPPTTTTTTTTRRRR
+	rev := code & 0xf
+	model := BoardModel(code >> 4 & 0xff)
+	soc := SoC(code >> 12 & 0xf)
+	manufacturer := code >> 16 & 0xf
+	memory := code >> 20 & 0x7
+
+	info := BoardInfo{
+		Revision:     code,
+		Model:        model,
+		PCBRevision:  fmt.Sprintf("1.%d", rev),
+		SoC:          soc,
+		MemoryMB:     256 << memory,
+		Manufacturer: "unknown",
+		Header:       Header40,
+	}
+	if soc > BCM2712 {
+		info.SoC = SoCUnknown
+	}
+	if _, ok := modelNames[model]; !ok {
+		info.Model = ModelUnknown
+	}
+	if int(manufacturer) < len(manufacturers) {
+		info.Manufacturer = manufacturers[manufacturer]
+	}
+
+	switch model {
+	case ModelA, ModelB:
+		info.Header = Header26Rev2
+	case ModelCM1, ModelCM3, ModelCM3Plus, ModelCM4, ModelCM4S, ModelCM5, ModelCM5Lite:
+		info.Header = NoHeader
+	}
+
+	return info, nil
+}
+
+var manufacturers = []string{"Sony UK", "Egoman", "Embest", "Sony Japan", "Embest", "Stadium"}
+
+var oldRevisions = map[uint32]BoardInfo{
+	0x0002: {Model: ModelB, PCBRevision: "1.0", MemoryMB: 256, Manufacturer: "Egoman", Header: Header26Rev1},
+	0x0003: {Model: ModelB, PCBRevision: "1.0", MemoryMB: 256, Manufacturer: "Egoman", Header: Header26Rev1},
+	0x0004: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Sony UK", Header: Header26Rev2},
+	0x0005: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Qisda", Header: Header26Rev2},
+	0x0006: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Egoman", Header: Header26Rev2},
+	0x0007: {Model: ModelA, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Egoman", Header: Header26Rev2},
+	0x0008: {Model: ModelA, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Sony UK", Header: Header26Rev2},
+	0x0009: {Model: ModelA, PCBRevision: "2.0", MemoryMB: 256, Manufacturer: "Qisda", Header: Header26Rev2},
+	0x000d: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 512, Manufacturer: "Egoman", Header: Header26Rev2},
+	0x000e: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 512, Manufacturer: "Sony UK", Header: Header26Rev2},
+	0x000f: {Model: ModelB, PCBRevision: "2.0", MemoryMB: 512, Manufacturer: "Egoman", Header: Header26Rev2},
+	0x0010: {Model: ModelBPlus, PCBRevision: "1.2", MemoryMB: 512, Manufacturer: "Sony UK", Header: Header40},
+	0x0011: {Model: ModelCM1, PCBRevision: "1.0", MemoryMB: 512, Manufacturer: "Sony UK", Header: NoHeader},
+	0x0012: {Model: ModelAPlus, PCBRevision: "1.1", MemoryMB: 256, Manufacturer: "Sony UK", Header: Header40},
+	0x0013: {Model: ModelBPlus, PCBRevision: "1.2", MemoryMB: 512, Manufacturer: "Embest", Header: Header40},
+	0x0014: {Model: ModelCM1, PCBRevision: "1.0", MemoryMB: 512, Manufacturer: "Embest", Header: NoHeader},
+	0x0015: {Model: ModelAPlus, PCBRevision: "1.1", MemoryMB: 256, Manufacturer: "Embest", Header: Header40},
+}
+
+func decodeOldRevision(code uint32) (BoardInfo, error) {
+	const warranty = 1 << 24 // set on overvolted boards
+
+	info, ok := oldRevisions[code&^warranty]
+	if !ok {
+		return BoardInfo{}, fmt.Errorf("rpio: unknown revision code %04x", code)
+	}
+	info.Revision = code
+	info.SoC = BCM2835
+	return info, nil
+}
+
+// readRevision reads the revision code from device tree or /proc/cpuinfo
+func readRevision() (uint32, error) {
+	if b, err := ioutil.ReadFile("/proc/device-tree/system/linux,revision"); err == nil && len(b) == 4 {
+		return binary.BigEndian.Uint32(b), nil
+	}
+
+	cpuinfo, err := os.Open("/proc/cpuinfo")
+	if err != nil {
+		return 0, err
+	}
+	defer cpuinfo.Close()
+
+	rev, err := parseCpuinfoRevision(cpuinfo)
+	if err != nil {
+		return 0, err
+	}
+	code, err := strconv.ParseUint(rev, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("rpio: invalid revision code %q", rev)
+	}
+	return uint32(code), nil
+}
+
+// parseCpuinfoRevision finds value of the "Revision" line in cpuinfo
+func parseCpuinfoRevision(r io.Reader) (string, error) {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		i := bytes.IndexByte(line, ':')
+		if i < 0 {
+			continue
+		}
+		if string(bytes.TrimSpace(line[:i])) == "Revision" {
+			return string(bytes.TrimSpace(line[i+1:])), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", err
+	}
+	return "", errors.New("rpio: revision not found in cpuinfo")
+}
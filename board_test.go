@@ -0,0 +1,73 @@
+package rpio
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRevision(t *testing.T) {
+	tests := []struct {
+		rev  string
+		want BoardInfo
+	}{
+		{"0002", BoardInfo{0x0002, ModelB, "1.0", BCM2835, 256, "Egoman", Header26Rev1}},
+		{"1000002", BoardInfo{0x1000002, ModelB, "1.0", BCM2835, 256, "Egoman", Header26Rev1}},
+		{"000e", BoardInfo{0x000e, ModelB, "2.0", BCM2835, 512, "Sony UK", Header26Rev2}},
+		{"0015", BoardInfo{0x0015, ModelAPlus, "1.1", BCM2835, 256, "Embest", Header40}},
+		{"900093", BoardInfo{0x900093, ModelZero, "1.3", BCM2835, 512, "Sony UK", Header40}},
+		{"9000c1", BoardInfo{0x9000c1, ModelZeroW, "1.1", BCM2835, 512, "Sony UK", Header40}},
+		{"a01041", BoardInfo{0xa01041, Model2B, "1.1", BCM2836, 1024, "Sony UK", Header40}},
+		{"a22082", BoardInfo{0xa22082, Model3B, "1.2", BCM2837, 1024, "Embest", Header40}},
+		{"a020d3", BoardInfo{0xa020d3, Model3BPlus, "1.3", BCM2837, 1024, "Sony UK", Header40}},
+		{"a02100", BoardInfo{0xa02100, ModelCM3Plus, "1.0", BCM2837, 1024, "Sony UK", NoHeader}},
+		{"c03111", BoardInfo{0xc03111, Model4B, "1.1", BCM2711, 4096, "Sony UK", Header40}},
+		{"d03114", BoardInfo{0xd03114, Model4B, "1.4", BCM2711, 8192, "Sony UK", Header40}},
+		{"b03140", BoardInfo{0xb03140, ModelCM4, "1.0", BCM2711, 2048, "Sony UK", NoHeader}},
+		{"c03130", BoardInfo{0xc03130, Model400, "1.0", BCM2711, 4096, "Sony UK", Header40}},
+		{"902120", BoardInfo{0x902120, ModelZero2W, "1.0", BCM2837, 512, "Sony UK", Header40}},
+		{"d04170", BoardInfo{0xd04170, Model5, "1.0", BCM2712, 8192, "Sony UK", Header40}},
+		{"0xc04170", BoardInfo{0xc04170, Model5, "1.0", BCM2712, 4096, "Sony UK", Header40}},
+	}
+
+	for _, test := range tests {
+		got, err := ParseRevision(test.rev)
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.rev, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: got %+v, want %+v", test.rev, got, test.want)
+		}
+	}
+
+	for _, rev := range []string{"", "zz", "0001", "000a"} {
+		if _, err := ParseRevision(rev); err == nil {
+			t.Errorf("%q: expected error", rev)
+		}
+	}
+}
+
+func TestParseCpuinfoRevision(t *testing.T) {
+	const cpuinfo = `processor	: 0
+model name	: ARMv7 Processor rev 4 (v7l)
+BogoMIPS	: 38.40
+Features	: half thumb fastmult vfp edsp neon vfpv3 tls vfpv4 idiva idivt vfpd32 lpae evtstrm crc32
+CPU implementer	: 0x41
+
+Hardware	: BCM2835
+Revision	: a020d3
+Serial		: 00000000d3f1c2e4
+Model		: Raspberry Pi 3 Model B Plus Rev 1.3
+`
+	rev, err := parseCpuinfoRevision(strings.NewReader(cpuinfo))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rev != "a020d3" {
+		t.Errorf("got revision %q", rev)
+	}
+
+	if _, err := parseCpuinfoRevision(strings.NewReader("processor	: 0\n")); err == nil {
+		t.Error("expected error for cpuinfo without revision")
+	}
+}
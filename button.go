@@ -0,0 +1,220 @@
+package rpio
+
+import (
+	"sync"
+	"time"
+)
+
+// ButtonGesture is a type of ButtonEvent
+type ButtonGesture uint8
+
+const (
+	ButtonPress       ButtonGesture = iota // button went down
+	ButtonRelease                          // button went up
+	ButtonClick                            // short press not followed by another one within DoubleClickTime
+	ButtonDoubleClick                      // two short presses
+	ButtonLongPress                        // button held for LongPressTime
+	ButtonRepeat                           // button still held after long press, every RepeatInterval
+)
+
+func (g ButtonGesture) String() string {
+	switch g {
+	case ButtonPress:
+		return "press"
+	case ButtonRelease:
+		return "release"
+	case ButtonClick:
+		return "click"
+	case ButtonDoubleClick:
+		return "double click"
+	case ButtonLongPress:
+		return "long press"
+	case ButtonRepeat:
+		return "repeat"
+	}
+	return "unknown"
+}
+
+// ButtonEvent is a gesture recognized on a Button
+type ButtonEvent struct {
+	Gesture ButtonGesture
+	Time    time.Duration // when the gesture was recognized
+	Repeat  int           // number of the repeat, for ButtonRepeat
+}
+
+// ButtonConfig: Settings of Button, zero durations mean defaults:
+// 20ms debounce, 300ms double click, 800ms long press. Zero RepeatInterval disables repeating.
+type ButtonConfig struct {
+	Active State // level of pressed button, Low for button to ground
+	Pull   Pull  // pull set on the pin, PullUp for button to ground
+
+	DebounceTime    time.Duration
+	DoubleClickTime time.Duration // max time between release and second press of a double click
+	LongPressTime   time.Duration
+	RepeatInterval  time.Duration
+}
+
+// Button recognizes clicks, double clicks, long presses and auto repeat on a debounced pin.
+// Gestures are sent to the Events channel, events are dropped if they are not consumed.
+type Button struct {
+	pin    *DebouncedPin
+	events chan ButtonEvent
+
+	mu      sync.Mutex
+	machine buttonMachine
+}
+
+// NewButton: Sets pin to Input mode with configured pull, see ButtonConfig.
+// Call Run to start recognition.
+func NewButton(pin Pin, config ButtonConfig) *Button {
+	pin.Input()
+	pin.Pull(config.Pull)
+	return newButton(NewDebouncedPin(pin, config.DebounceTime, config.Active), config)
+}
+
+func newButton(pin *DebouncedPin, config ButtonConfig) *Button {
+	if config.DebounceTime == 0 {
+		config.DebounceTime = 20 * time.Millisecond
+	}
+	if config.DoubleClickTime == 0 {
+		config.DoubleClickTime = 300 * time.Millisecond
+	}
+	if config.LongPressTime == 0 {
+		config.LongPressTime = 800 * time.Millisecond
+	}
+	pin.StableTime = config.DebounceTime
+	return &Button{
+		pin:     pin,
+		events:  make(chan ButtonEvent, 64),
+		machine: buttonMachine{config: config},
+	}
+}
+
+// Events returns channel of recognized gestures
+func (b *Button) Events() <-chan ButtonEvent {
+	return b.events
+}
+
+// Pressed checks whether the button is pressed (debounced)
+func (b *Button) Pressed() bool {
+	return b.pin.Pressed()
+}
+
+// Run: Polls the button every millisecond until stop is closed.
+// Blocks, so run it in a goroutine.
+func (b *Button) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		b.pin.Poll()
+		b.Update(systemTime())
+	}
+}
+
+// Update processes debounced changes of the pin and timeouts of gestures until now.
+// It is called by Run, or can be called directly with a custom time source.
+func (b *Button) Update(now time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for {
+		select {
+		case event := <-b.pin.Events():
+			b.machine.tick(event.Time, b.emit)
+			if event.Pressed {
+				b.machine.press(event.Time, b.emit)
+			} else {
+				b.machine.release(event.Time, b.emit)
+			}
+			continue
+		default:
+		}
+		break
+	}
+	b.machine.tick(now, b.emit)
+}
+
+func (b *Button) emit(event ButtonEvent) {
+	select {
+	case b.events <- event:
+	default:
+	}
+}
+
+// buttonMachine is the gesture state machine, driven by debounced presses and releases
+// and by passing time, all with explicit timestamps.
+type buttonMachine struct {
+	config ButtonConfig
+
+	pressed     bool
+	pressTime   time.Duration
+	releaseTime time.Duration
+	clickWait   bool // short press released, waiting for a second one
+	second      bool // current press is second press of a double click
+	long        bool // long press reported for current press
+	repeats     int
+	nextRepeat  time.Duration
+}
+
+func (m *buttonMachine) press(now time.Duration, emit func(ButtonEvent)) {
+	if m.pressed {
+		return
+	}
+	m.pressed = true
+	m.pressTime = now
+	m.long = false
+	m.second = m.clickWait
+	m.clickWait = false
+	emit(ButtonEvent{Gesture: ButtonPress, Time: now})
+}
+
+func (m *buttonMachine) release(now time.Duration, emit func(ButtonEvent)) {
+	if !m.pressed {
+		return
+	}
+	m.pressed = false
+	emit(ButtonEvent{Gesture: ButtonRelease, Time: now})
+
+	switch {
+	case m.long:
+	case m.second:
+		emit(ButtonEvent{Gesture: ButtonDoubleClick, Time: now})
+	default:
+		m.clickWait = true
+		m.releaseTime = now
+	}
+	m.second = false
+}
+
+func (m *buttonMachine) tick(now time.Duration, emit func(ButtonEvent)) {
+	if m.clickWait && now-m.releaseTime > m.config.DoubleClickTime {
+		m.clickWait = false
+		emit(ButtonEvent{Gesture: ButtonClick, Time: m.releaseTime + m.config.DoubleClickTime})
+	}
+	if !m.pressed {
+		return
+	}
+
+	if !m.long && now-m.pressTime >= m.config.LongPressTime {
+		at := m.pressTime + m.config.LongPressTime
+		if m.second { // first press was a click after all
+			m.second = false
+			emit(ButtonEvent{Gesture: ButtonClick, Time: at})
+		}
+		m.long = true
+		m.repeats = 0
+		m.nextRepeat = at + m.config.RepeatInterval
+		emit(ButtonEvent{Gesture: ButtonLongPress, Time: at})
+	}
+
+	for m.long && m.config.RepeatInterval > 0 && now >= m.nextRepeat {
+		m.repeats++
+		emit(ButtonEvent{Gesture: ButtonRepeat, Time: m.nextRepeat, Repeat: m.repeats})
+		m.nextRepeat += m.config.RepeatInterval
+	}
+}
@@ -0,0 +1,68 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestButtonGestures(t *testing.T) {
+	ms := time.Millisecond
+	type edge struct {
+		at    time.Duration
+		level State
+	}
+	tests := []struct {
+		name  string
+		edges []edge
+		until time.Duration
+		want  []ButtonGesture
+	}{
+		{"click", []edge{{100 * ms, Low}, {200 * ms, High}}, 2000 * ms,
+			[]ButtonGesture{ButtonPress, ButtonRelease, ButtonClick}},
+		{"double click", []edge{{100 * ms, Low}, {200 * ms, High}, {300 * ms, Low}, {400 * ms, High}}, 2000 * ms,
+			[]ButtonGesture{ButtonPress, ButtonRelease, ButtonPress, ButtonRelease, ButtonDoubleClick}},
+		{"two clicks", []edge{{100 * ms, Low}, {200 * ms, High}, {700 * ms, Low}, {800 * ms, High}}, 2000 * ms,
+			[]ButtonGesture{ButtonPress, ButtonRelease, ButtonClick, ButtonPress, ButtonRelease, ButtonClick}},
+		{"long press with repeat", []edge{{100 * ms, Low}, {1150 * ms, High}}, 2000 * ms,
+			[]ButtonGesture{ButtonPress, ButtonLongPress, ButtonRepeat, ButtonRepeat, ButtonRelease}},
+		{"click then long press", []edge{{100 * ms, Low}, {200 * ms, High}, {300 * ms, Low}}, 1250 * ms,
+			[]ButtonGesture{ButtonPress, ButtonRelease, ButtonPress, ButtonClick, ButtonLongPress, ButtonRepeat}},
+	}
+
+	for _, test := range tests {
+		pin := newDebouncedPin(5, 0, Low, High, 0)
+		b := newButton(pin, ButtonConfig{Active: Low, RepeatInterval: 100 * ms})
+
+		// fake clock ticking every millisecond
+		next := 0
+		for now := time.Duration(0); now <= test.until; now += ms {
+			for next < len(test.edges) && test.edges[next].at <= now {
+				pin.Add(EdgeEvent{Pin: 5, Level: test.edges[next].level, Time: test.edges[next].at})
+				next++
+			}
+			pin.Update(now)
+			b.Update(now)
+		}
+
+		var got []ButtonGesture
+	loop:
+		for {
+			select {
+			case event := <-b.Events():
+				got = append(got, event.Gesture)
+			default:
+				break loop
+			}
+		}
+		if len(got) != len(test.want) {
+			t.Errorf("%s: gestures %v, want %v", test.name, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: gestures %v, want %v", test.name, got, test.want)
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package rpio
+
+import "fmt"
+
+type PullType uint8
+type Peripheral uint16
+
+// How pull up/down is configured on the chip
+const (
+	PullClocked  PullType = iota // GPPUD and GPPUDCLK sequence (BCM2835, BCM2836, BCM2837)
+	PullRegister                 // GPPUPPDN registers, 2 bits per pin, readable (BCM2711)
+	PullPads                     // RP1 pad control registers, readable (BCM2712)
+)
+
+// Peripherals supported by this library on the chip
+const (
+	PeriphGpio Peripheral = 1 << iota
+	PeriphEdgeDetect
+	PeriphClock
+	PeriphPwm
+	PeriphSpi
+	PeriphIrq
+	PeriphUart
+	PeriphAux // mini uart
+	PeriphPcm
+	PeriphTimer
+	PeriphDma
+)
+
+// Chip describes differences between SoCs of the Raspberry Pi family
+type Chip struct {
+	SoC            SoC
+	PeripheralBase int64 // default peripheral base, used when it can't be read from device tree
+	OscillatorFreq int   // [Hz] clock source used by SetFreq
+	CoreFreq       int   // [Hz] core (VPU) clock, source of SPI clock
+	UartFreq       int   // [Hz] PL011 uart reference clock (init_uart_clock set by firmware)
+	PllDFreq       int   // [Hz] PLLD clock source, used for DMA pacing
+	PinCount       int
+	Pull           PullType
+	Peripherals    Peripheral
+}
+
+const bcmPeripherals = PeriphGpio | PeriphEdgeDetect | PeriphClock | PeriphPwm | PeriphSpi | PeriphIrq |
+	PeriphUart | PeriphAux | PeriphPcm | PeriphTimer | PeriphDma
+
+var chips = map[SoC]Chip{
+	BCM2835: {
+		SoC:            BCM2835,
+		PeripheralBase: bcm2835Base,
+		OscillatorFreq: 19200000,
+		CoreFreq:       250000000,
+		UartFreq:       48000000,
+		PllDFreq:       500000000,
+		PinCount:       54,
+		Pull:           PullClocked,
+		Peripherals:    bcmPeripherals,
+	},
+	BCM2836: {
+		SoC:            BCM2836,
+		PeripheralBase: 0x3F000000,
+		OscillatorFreq: 19200000,
+		CoreFreq:       250000000,
+		UartFreq:       48000000,
+		PllDFreq:       500000000,
+		PinCount:       54,
+		Pull:           PullClocked,
+		Peripherals:    bcmPeripherals,
+	},
+	BCM2837: {
+		SoC:            BCM2837,
+		PeripheralBase: 0x3F000000,
+		OscillatorFreq: 19200000,
+		CoreFreq:       250000000,
+		UartFreq:       48000000,
+		PllDFreq:       500000000,
+		PinCount:       54,
+		Pull:           PullClocked,
+		Peripherals:    bcmPeripherals,
+	},
+	BCM2711: {
+		SoC:            BCM2711,
+		PeripheralBase: 0xFE000000,
+		OscillatorFreq: 52000000,
+		CoreFreq:       550000000,
+		UartFreq:       48000000,
+		PllDFreq:       750000000,
+		PinCount:       58,
+		Pull:           PullRegister,
+		Peripherals:    bcmPeripherals,
+	},
+	BCM2712: { // gpio is in the RP1 southbridge
+		SoC:            BCM2712,
+		PeripheralBase: rp1Base,
+		OscillatorFreq: 50000000,
+		CoreFreq:       200000000,
+		UartFreq:       48000000,
+		PinCount:       rp1PinCount,
+		Pull:           PullPads,
+		Peripherals:    PeriphGpio,
+	},
+}
+
+// chip detected by Open
+var currentChip = chips[BCM2835]
+
+// CurrentChip returns description of the SoC detected by Open.
+// Before Open is called, it describes BCM2835.
+func CurrentChip() Chip {
+	return currentChip
+}
+
+// Has checks whether the peripheral is supported on the chip
+func (c Chip) Has(p Peripheral) bool {
+	return c.Peripherals&p == p
+}
+
+func (c Chip) String() string {
+	return c.SoC.String()
+}
+
+// detectChip determines SoC from the board revision code,
+// or from the peripheral base address if the revision is not available.
+func detectChip() (Chip, error) {
+	if board, err := Board(); err == nil {
+		if chip, ok := chips[board.SoC]; ok {
+			return chip, nil
+		}
+		return Chip{}, fmt.Errorf("rpio: unsupported SoC of %s", board)
+	}
+
+	return chipForBase(getBase()), nil
+}
+
+// chipForBase guesses chip from peripheral base address, defaults to BCM2835
+func chipForBase(base int64) Chip {
+	for _, soc := range []SoC{BCM2835, BCM2837, BCM2711} {
+		if chips[soc].PeripheralBase == base {
+			return chips[soc]
+		}
+	}
+	return chips[BCM2835]
+}
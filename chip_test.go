@@ -0,0 +1,34 @@
+package rpio
+
+import "testing"
+
+func TestChipForBase(t *testing.T) {
+	tests := map[int64]SoC{
+		0x20000000: BCM2835,
+		0x3F000000: BCM2837,
+		0xFE000000: BCM2711,
+		0x12345678: BCM2835,
+	}
+	for base, want := range tests {
+		if chip := chipForBase(base); chip.SoC != want {
+			t.Errorf("base %#x: got %s, want %s", base, chip, want)
+		}
+	}
+}
+
+func TestChipPeripherals(t *testing.T) {
+	for soc, chip := range chips {
+		if chip.SoC != soc {
+			t.Errorf("%s: table entry describes %s", soc, chip.SoC)
+		}
+		if !chip.Has(PeriphGpio) {
+			t.Errorf("%s: gpio should be supported", soc)
+		}
+	}
+	if chips[BCM2712].Has(PeriphSpi | PeriphGpio) {
+		t.Error("BCM2712: spi should not be supported")
+	}
+	if !chips[BCM2711].Has(PeriphSpi | PeriphPwm) {
+		t.Error("BCM2711: spi and pwm should be supported")
+	}
+}
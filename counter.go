@@ -0,0 +1,195 @@
+package rpio
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// CounterConfig: Settings of Counter, zero values mean rising edges, 1 pulse per revolution and 1s gate time
+type CounterConfig struct {
+	Edge                Edge // counted edges: RiseEdge, FallEdge or AnyEdge
+	PulsesPerRevolution int  // eg. 2 for most PC fans
+	GateTime            time.Duration
+}
+
+// Counter counts edges on an input pin and measures frequency (tachometer, flow meter).
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available, they are timestamped
+// and queued by the kernel, so no pulse is lost while the program is not scheduled.
+// Otherwise the pin is polled every 100µs with edge detection (see Pin.Detect),
+// which loses edges of signals faster than the polling.
+//
+// Edges that were certainly missed (two rising or two falling edges in a row) are flagged, see Missed.
+type Counter struct {
+	pin    Pin
+	config CounterConfig
+	now    func() time.Duration
+
+	mu         sync.Mutex
+	count      uint64
+	times      []time.Duration // of counted edges within gate time
+	lastRising bool
+	started    bool
+	missed     bool
+
+	file *os.File      // kernel line events
+	stop chan struct{} // polling
+	done chan struct{}
+}
+
+// NewCounter: Starts counting edges of pin, see Counter. Call Close when done.
+func NewCounter(pin Pin, config CounterConfig) (*Counter, error) {
+	c := newCounter(pin, config, systemTime)
+	c.done = make(chan struct{})
+
+	file, err := openLineEvents(pin, AnyEdge, "rpio-counter")
+	if err == nil {
+		c.file = file
+		go func() {
+			defer close(c.done)
+			readLineEvents(file, func(event lineEvent) {
+				c.add(event.Rising, event.Time)
+			})
+		}()
+		return c, nil
+	}
+
+	if gpioMem == nil && rp1Mem == nil {
+		return nil, err
+	}
+	pin.Input()
+	pin.Detect(AnyEdge)
+	c.stop = make(chan struct{})
+	go c.poll()
+	return c, nil
+}
+
+func newCounter(pin Pin, config CounterConfig, now func() time.Duration) *Counter {
+	if config.Edge == NoEdge {
+		config.Edge = RiseEdge
+	}
+	if config.PulsesPerRevolution <= 0 {
+		config.PulsesPerRevolution = 1
+	}
+	if config.GateTime <= 0 {
+		config.GateTime = time.Second
+	}
+	return &Counter{pin: pin, config: config, now: now}
+}
+
+// poll reads pin level and edge detection status until stopped
+func (c *Counter) poll() {
+	defer close(c.done)
+
+	ticker := time.NewTicker(100 * time.Microsecond)
+	defer ticker.Stop()
+
+	EdgeDetected(c.pin) // clear
+	level := ReadPin(c.pin)
+	for {
+		select {
+		case <-c.stop:
+			return
+		case <-ticker.C:
+		}
+
+		edge := EdgeDetected(c.pin)
+		l := ReadPin(c.pin)
+		now := c.now()
+		switch {
+		case l != level:
+			c.add(l == High, now)
+		case edge: // a pulse shorter than the polling period
+			c.add(l != High, now)
+			c.add(l == High, now)
+		}
+		level = l
+	}
+}
+
+// add processes edge, edges have to be added in order
+func (c *Counter) add(rising bool, t time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.started && rising == c.lastRising {
+		c.missed = true
+	}
+	c.started = true
+	c.lastRising = rising
+
+	if rising && c.config.Edge&RiseEdge == 0 || !rising && c.config.Edge&FallEdge == 0 {
+		return
+	}
+	c.count++
+	c.times = append(c.times, t)
+	c.prune(t)
+}
+
+// prune drops edge times older than gate time
+func (c *Counter) prune(now time.Duration) {
+	i := 0
+	for i < len(c.times) && now-c.times[i] > c.config.GateTime {
+		i++
+	}
+	c.times = c.times[i:]
+}
+
+// Count returns number of counted edges since start or Reset
+func (c *Counter) Count() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.count
+}
+
+// Missed checks whether some edges were missed since start or Reset
+func (c *Counter) Missed() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.missed
+}
+
+// Reset clears the count and the missed flag
+func (c *Counter) Reset() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count = 0
+	c.times = c.times[:0]
+	c.missed = false
+}
+
+// Frequency returns frequency [Hz] of pulses, counted during the last gate time
+func (c *Counter) Frequency() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prune(c.now())
+	pulses := float64(len(c.times))
+	if c.config.Edge == AnyEdge {
+		pulses /= 2
+	}
+	return pulses / c.config.GateTime.Seconds()
+}
+
+// RPM returns revolutions per minute, according to PulsesPerRevolution
+func (c *Counter) RPM() float64 {
+	return c.Frequency() * 60 / float64(c.config.PulsesPerRevolution)
+}
+
+// Close stops counting and releases the pin
+func (c *Counter) Close() error {
+	if c.file != nil {
+		err := c.file.Close()
+		<-c.done
+		c.file = nil
+		return err
+	}
+	if c.stop != nil {
+		close(c.stop)
+		<-c.done
+		c.stop = nil
+		c.pin.Detect(NoEdge)
+	}
+	return nil
+}
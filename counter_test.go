@@ -0,0 +1,70 @@
+package rpio
+
+import (
+	"encoding/binary"
+	"testing"
+	"time"
+)
+
+func TestCounter(t *testing.T) {
+	var now time.Duration
+	c := newCounter(17, CounterConfig{PulsesPerRevolution: 2}, func() time.Duration { return now })
+
+	// 100Hz for 2 seconds
+	for i := 0; i < 200; i++ {
+		c.add(true, now)
+		c.add(false, now+5*time.Millisecond)
+		now += 10 * time.Millisecond
+	}
+	if n := c.Count(); n != 200 {
+		t.Errorf("count %d, want 200", n)
+	}
+	if f := c.Frequency(); f != 100 {
+		t.Errorf("frequency %v, want 100", f)
+	}
+	if rpm := c.RPM(); rpm != 3000 {
+		t.Errorf("rpm %v, want 3000", rpm)
+	}
+	if c.Missed() {
+		t.Errorf("missed edges reported")
+	}
+
+	c.add(true, now)
+	c.add(true, now+time.Millisecond) // falling edge lost
+	if !c.Missed() {
+		t.Errorf("missed edges not reported")
+	}
+	c.Reset()
+	if c.Count() != 0 || c.Missed() {
+		t.Errorf("not reset")
+	}
+
+	now += 2 * time.Second
+	if f := c.Frequency(); f != 0 {
+		t.Errorf("frequency %v after signal stopped, want 0", f)
+	}
+}
+
+func TestCounterBothEdges(t *testing.T) {
+	var now time.Duration
+	c := newCounter(17, CounterConfig{Edge: AnyEdge, GateTime: 100 * time.Millisecond}, func() time.Duration { return now })
+	for i := 0; i < 100; i++ {
+		c.add(i%2 == 0, now)
+		now += time.Millisecond
+	}
+	if n := c.Count(); n != 100 {
+		t.Errorf("count %d, want 100", n)
+	}
+	if f := c.Frequency(); f != 500 {
+		t.Errorf("frequency %v, want 500", f)
+	}
+}
+
+func TestParseLineEvent(t *testing.T) {
+	data := make([]byte, gpioEventDataSize)
+	binary.LittleEndian.PutUint64(data, 1234567890)
+	binary.LittleEndian.PutUint32(data[8:], 2) // falling
+	if e := parseLineEvent(data); e.Rising || e.Time != 1234567890 {
+		t.Errorf("event %+v", e)
+	}
+}
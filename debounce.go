@@ -0,0 +1,156 @@
+package rpio
+
+import (
+	"sync"
+	"time"
+)
+
+// DebounceEvent is a debounced change of pin level
+type DebounceEvent struct {
+	Pin     Pin
+	Pressed bool          // pin is at the active level
+	Level   State         // level after the change
+	Time    time.Duration // of the first edge of the stable level, in the time base of the edge source
+}
+
+// DebouncedPin filters bounces of a mechanical switch: level change is accepted
+// only after the pin stays at the new level for StableTime.
+//
+// It can be fed by polling (Poll, which also uses edge detection if enabled by Pin.Detect),
+// or by edge events from any source (Add, or Watch which uses WatchEdges).
+// Accepted changes are sent to the Events channel, events are dropped if they are not consumed.
+type DebouncedPin struct {
+	Pin        Pin
+	StableTime time.Duration
+	Active     State // level of pressed switch, Low for switch to ground with pull up
+
+	mu      sync.Mutex
+	state   State         // debounced level
+	raw     State         // last seen level
+	rawTime time.Duration // time of last seen change
+	events  chan DebounceEvent
+}
+
+// NewDebouncedPin: Sets pin to Input mode and starts debouncing from its current level.
+// Pull up/down has to be set by the caller.
+func NewDebouncedPin(pin Pin, stableTime time.Duration, active State) *DebouncedPin {
+	pin.Input()
+	return newDebouncedPin(pin, stableTime, active, ReadPin(pin), systemTime())
+}
+
+func newDebouncedPin(pin Pin, stableTime time.Duration, active State, level State, now time.Duration) *DebouncedPin {
+	return &DebouncedPin{
+		Pin:        pin,
+		StableTime: stableTime,
+		Active:     active,
+		state:      level,
+		raw:        level,
+		rawTime:    now,
+		events:     make(chan DebounceEvent, 64),
+	}
+}
+
+// Events returns channel of accepted level changes
+func (d *DebouncedPin) Events() <-chan DebounceEvent {
+	return d.events
+}
+
+// State returns debounced level of the pin
+func (d *DebouncedPin) State() State {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.state
+}
+
+// Pressed checks whether debounced level is the active level
+func (d *DebouncedPin) Pressed() bool {
+	return d.State() == d.Active
+}
+
+// Add processes raw edge event, events have to be added in order.
+// Call Update when no edges come, so the last level can be accepted.
+func (d *DebouncedPin) Add(event EdgeEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.update(event.Time)
+	d.raw = event.Level
+	d.rawTime = event.Time
+}
+
+// Update accepts the last seen level if it was stable for StableTime until now
+func (d *DebouncedPin) Update(now time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.update(now)
+}
+
+func (d *DebouncedPin) update(now time.Duration) {
+	if d.raw == d.state || now-d.rawTime < d.StableTime {
+		return
+	}
+	d.state = d.raw
+	event := DebounceEvent{Pin: d.Pin, Pressed: d.state == d.Active, Level: d.state, Time: d.rawTime}
+	select {
+	case d.events <- event:
+	default:
+	}
+}
+
+// Poll reads the pin and updates debounced state, call it periodically (more often than StableTime).
+//
+// If edge detection is enabled on the pin (see Pin.Detect), bounces shorter than the polling period
+// also restart the stable time.
+func (d *DebouncedPin) Poll() {
+	now := systemTime()
+	level := ReadPin(d.Pin)
+	edge := EdgeDetected(d.Pin)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if level != d.raw || edge {
+		d.update(now)
+		d.raw = level
+		d.rawTime = now
+	}
+	d.update(now)
+}
+
+// Watch: Debounces edges of the pin until stop is closed. Blocks, so run it in a goroutine.
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available, otherwise the pin
+// is polled in a busy loop (see WatchEdges). Returns immediately if neither is available.
+func (d *DebouncedPin) Watch(stop <-chan struct{}) {
+	edges, file, err := watchLineEdges(d.Pin, "rpio-debounce", stop)
+	if err != nil {
+		return
+	}
+	if file != nil {
+		defer file.Close()
+	}
+
+	timer := time.NewTimer(d.StableTime)
+	defer timer.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case event, ok := <-edges:
+			if !ok {
+				return
+			}
+			d.Add(event)
+			if !timer.Stop() {
+				select { // fired meanwhile
+				case <-timer.C:
+				default:
+				}
+			}
+			timer.Reset(d.StableTime)
+		case <-timer.C:
+			timer.Reset(d.StableTime)
+		}
+		d.Update(systemTime())
+	}
+}
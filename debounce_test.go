@@ -0,0 +1,50 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDebouncedPin(t *testing.T) {
+	ms := time.Millisecond
+	d := newDebouncedPin(22, 10*ms, Low, High, 0)
+
+	// press with bounces
+	for i, level := range []State{Low, High, Low, High, Low} {
+		d.Add(EdgeEvent{Pin: 22, Level: level, Time: 100*ms + time.Duration(i)*ms})
+	}
+	d.Update(110 * ms)
+	if d.Pressed() {
+		t.Errorf("pressed before stable time")
+	}
+	d.Update(114 * ms)
+	if !d.Pressed() {
+		t.Errorf("not pressed after stable time")
+	}
+
+	// release, accepted by next edge
+	d.Add(EdgeEvent{Pin: 22, Level: High, Time: 200 * ms})
+	d.Add(EdgeEvent{Pin: 22, Level: Low, Time: 250 * ms})
+	d.Add(EdgeEvent{Pin: 22, Level: High, Time: 252 * ms}) // short glitch ignored
+	d.Update(300 * ms)
+
+	want := []DebounceEvent{
+		{Pin: 22, Pressed: true, Level: Low, Time: 104 * ms},
+		{Pin: 22, Pressed: false, Level: High, Time: 200 * ms},
+	}
+	for _, w := range want {
+		select {
+		case event := <-d.Events():
+			if event != w {
+				t.Errorf("event %+v, want %+v", event, w)
+			}
+		default:
+			t.Errorf("missing event %+v", w)
+		}
+	}
+	select {
+	case event := <-d.Events():
+		t.Errorf("unexpected event %+v", event)
+	default:
+	}
+}
@@ -0,0 +1,169 @@
+package rpio
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DHTModel selects data format and timing of the sensor
+type DHTModel uint8
+
+const (
+	DHT11 DHTModel = iota
+	DHT22          // also AM2302
+)
+
+var (
+	DHTTimeoutError  = errors.New("DHT sensor response incomplete")
+	DHTChecksumError = errors.New("DHT checksum mismatch")
+)
+
+// dhtPulse is a captured level of the data line
+type dhtPulse struct {
+	level  State
+	length time.Duration
+}
+
+// DHT is a driver of DHT11/DHT22 temperature and humidity sensor.
+//
+// The data pin has to be pulled up (most modules have the resistor on board).
+// The response is captured by reading the pin in a tight loop for about 5ms,
+// reads disturbed by preemption are detected by checksum and retried.
+type DHT struct {
+	pin     DigitalPin
+	model   DHTModel
+	Retries int // attempts after failed read, default 3
+
+	now   func() time.Duration
+	sleep func(time.Duration)
+
+	mu       sync.Mutex
+	lastRead time.Duration
+	read     bool
+}
+
+// NewDHT creates driver of the sensor connected to pin
+func NewDHT(pin DigitalPin, model DHTModel) *DHT {
+	pin.Input()
+	return &DHT{pin: pin, model: model, Retries: 3, now: systemTime, sleep: time.Sleep}
+}
+
+// minInterval returns minimal time between two reads of the sensor
+func (d *DHT) minInterval() time.Duration {
+	if d.model == DHT11 {
+		return time.Second
+	}
+	return 2 * time.Second
+}
+
+// Read reads temperature [°C] and relative humidity [%].
+//
+// Failed reads are retried, waiting the minimal sampling interval of the sensor (1s for DHT11, 2s for DHT22)
+// doubled after each failure. Reads sooner than the interval after the previous one wait as well.
+func (d *DHT) Read() (temperature, humidity float64, err error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	wait := d.minInterval()
+	for attempt := 0; attempt <= d.Retries; attempt++ {
+		if attempt > 0 {
+			d.sleep(wait)
+			wait *= 2
+		} else if since := d.now() - d.lastRead; d.read && since < wait {
+			d.sleep(wait - since)
+		}
+
+		var data [5]byte
+		data, err = decodeDHT(d.capture())
+		d.lastRead, d.read = d.now(), true
+		if err == nil {
+			temperature, humidity = dhtValues(d.model, data)
+			return temperature, humidity, nil
+		}
+	}
+	return 0, 0, err
+}
+
+// capture sends start signal and records levels of the response
+func (d *DHT) capture() []dhtPulse {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	start := 1100 * time.Microsecond
+	if d.model == DHT11 {
+		start = 18 * time.Millisecond
+	}
+	d.pin.Write(Low)
+	d.pin.Output()
+	d.sleep(start)
+	d.pin.Input()
+
+	// release high, response low and high, 40 bits of low and high, final low
+	const pulses = 1 + 2 + 2*40 + 1
+	trace := make([]dhtPulse, 0, pulses)
+
+	level := High
+	begin := d.now()
+	deadline := begin + 10*time.Millisecond
+	for len(trace) < pulses {
+		now := d.now()
+		if now > deadline {
+			break
+		}
+		if l := d.pin.Read(); l != level {
+			trace = append(trace, dhtPulse{level: level, length: now - begin})
+			level = l
+			begin = now
+		}
+	}
+	return trace
+}
+
+// decodeDHT decodes 5 bytes of data from captured levels and validates checksum.
+//
+// Each bit is a low pulse (50µs) followed by a high pulse, short (26-28µs) for 0 and long (70µs) for 1.
+// The bits are the last 40 high pulses preceded by a low pulse, the high pulse is compared to it,
+// so the decoding does not depend on the speed of polling.
+func decodeDHT(trace []dhtPulse) (data [5]byte, err error) {
+	var bits []bool
+	for i := 1; i < len(trace); i++ {
+		if trace[i].level == High && trace[i-1].level == Low {
+			bits = append(bits, trace[i].length > trace[i-1].length)
+		}
+	}
+	if len(bits) < 40 {
+		return data, DHTTimeoutError
+	}
+	bits = bits[len(bits)-40:]
+
+	for i, bit := range bits {
+		if bit {
+			data[i/8] |= 0x80 >> uint(i%8)
+		}
+	}
+	if data[0]+data[1]+data[2]+data[3] != data[4] {
+		return data, DHTChecksumError
+	}
+	return data, nil
+}
+
+// dhtValues converts data to temperature [°C] and humidity [%]
+func dhtValues(model DHTModel, data [5]byte) (temperature, humidity float64) {
+	if model == DHT11 {
+		humidity = float64(data[0]) + float64(data[1])/10
+		temperature = float64(data[2]) + float64(data[3]&0x7f)/10
+		if data[3]&0x80 != 0 {
+			temperature = -temperature
+		}
+		return temperature, humidity
+	}
+
+	humidity = float64(uint16(data[0])<<8|uint16(data[1])) / 10
+	temperature = float64(uint16(data[2]&0x7f)<<8|uint16(data[3])) / 10
+	if data[2]&0x80 != 0 {
+		temperature = -temperature
+	}
+	return temperature, humidity
+}
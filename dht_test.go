@@ -0,0 +1,128 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+// dhtTrace creates trace of sensor response with given data, bit lengths in µs
+func dhtTrace(data []byte, zero, one int) []dhtPulse {
+	us := time.Microsecond
+	trace := []dhtPulse{{High, 30 * us}, {Low, 80 * us}, {High, 80 * us}}
+	for _, b := range data {
+		for i := 7; i >= 0; i-- {
+			high := zero
+			if b>>uint(i)&1 != 0 {
+				high = one
+			}
+			trace = append(trace, dhtPulse{Low, 50 * us}, dhtPulse{High, time.Duration(high) * us})
+		}
+	}
+	return append(trace, dhtPulse{Low, 50 * us})
+}
+
+func TestDecodeDHT(t *testing.T) {
+	tests := []struct {
+		model     DHTModel
+		data      []byte
+		zero, one int
+		temp, hum float64
+		err       error
+	}{
+		{DHT22, []byte{0x02, 0x8c, 0x01, 0x5f, 0xee}, 27, 70, 35.1, 65.2, nil},
+		{DHT22, []byte{0x02, 0x8c, 0x80, 0x65, 0x73}, 27, 70, -10.1, 65.2, nil},
+		{DHT11, []byte{0x2d, 0x00, 0x17, 0x03, 0x47}, 24, 71, 23.3, 45, nil},
+		{DHT22, []byte{0x02, 0x8c, 0x01, 0x5f, 0xef}, 27, 70, 0, 0, DHTChecksumError},
+		{DHT22, []byte{0x02, 0x8c, 0x01, 0x5f, 0xee}, 48, 52, 35.1, 65.2, nil}, // slow polling of timer
+	}
+	for _, test := range tests {
+		data, err := decodeDHT(dhtTrace(test.data, test.zero, test.one))
+		if err != test.err {
+			t.Errorf("%x: error %v, want %v", test.data, err, test.err)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+		temp, hum := dhtValues(test.model, data)
+		if temp != test.temp || hum != test.hum {
+			t.Errorf("%x: %v°C %v%%, want %v°C %v%%", test.data, temp, hum, test.temp, test.hum)
+		}
+	}
+
+	// response cut by preemption
+	trace := dhtTrace([]byte{0x02, 0x8c, 0x01, 0x5f, 0xee}, 27, 70)
+	if _, err := decodeDHT(trace[:60]); err != DHTTimeoutError {
+		t.Errorf("truncated trace: error %v, want %v", err, DHTTimeoutError)
+	}
+}
+
+// dhtSimPin plays sensor responses in virtual time, advanced by reads and sleeps
+type dhtSimPin struct {
+	now       time.Duration
+	start     time.Duration // when start signal was released
+	responses [][]dhtPulse
+	current   []dhtPulse
+	slept     []time.Duration
+}
+
+func (p *dhtSimPin) Output()       {}
+func (p *dhtSimPin) Write(s State) {}
+
+func (p *dhtSimPin) Input() {
+	p.start = p.now
+	p.current = nil
+	if len(p.responses) > 0 {
+		p.current, p.responses = p.responses[0], p.responses[1:]
+	}
+}
+
+func (p *dhtSimPin) Read() State {
+	p.now += time.Microsecond
+	t := p.start
+	for _, pulse := range p.current {
+		t += pulse.length
+		if p.now < t {
+			return pulse.level
+		}
+	}
+	return High
+}
+
+func (p *dhtSimPin) sleep(d time.Duration) {
+	p.slept = append(p.slept, d)
+	p.now += d
+}
+
+func TestDHTRead(t *testing.T) {
+	good := dhtTrace([]byte{0x02, 0x8c, 0x01, 0x5f, 0xee}, 27, 70)
+	pin := &dhtSimPin{}
+	d := NewDHT(pin, DHT22)
+	pin.responses = [][]dhtPulse{good[:30], good[:50], good}
+	d.now = func() time.Duration { return pin.now }
+	d.sleep = pin.sleep
+
+	temp, hum, err := d.Read()
+	if err != nil || temp != 35.1 || hum != 65.2 {
+		t.Fatalf("read %v°C %v%% %v", temp, hum, err)
+	}
+	// start signals and backoff 2s, 4s
+	want := []time.Duration{1100 * time.Microsecond, 2 * time.Second, 1100 * time.Microsecond, 4 * time.Second, 1100 * time.Microsecond}
+	if len(pin.slept) != len(want) {
+		t.Fatalf("slept %v, want %v", pin.slept, want)
+	}
+	for i := range want {
+		if pin.slept[i] != want[i] {
+			t.Errorf("slept %v, want %v", pin.slept, want)
+			break
+		}
+	}
+
+	d.Retries = 0
+	if _, _, err := d.Read(); err != DHTTimeoutError { // no response, after min interval
+		t.Errorf("error %v, want %v", err, DHTTimeoutError)
+	}
+	if last := pin.slept[len(pin.slept)-2]; last != 2*time.Second {
+		t.Errorf("waited %v before next read, want 2s", last)
+	}
+}
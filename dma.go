@@ -0,0 +1,172 @@
+package rpio
+
+import (
+	"os"
+	"syscall"
+)
+
+// DMA channel registers, each channel has 0x100 bytes
+const (
+	dmaCsReg      = 0x00 / 4
+	dmaConblkReg  = 0x04 / 4 // address of control block
+	dmaDebugReg   = 0x20 / 4
+	dmaEnableReg  = 0xff0 / 4 // global enable bits of channels
+	dmaChannelLen = 0x100 / 4
+)
+
+// DMA_CS bits
+const (
+	dmaActive = 1 << 0
+	dmaEnd    = 1 << 1
+	dmaInt    = 1 << 2
+	dmaAbort  = 1 << 30
+	dmaReset  = 1 << 31
+
+	dmaWaitWrites = 1 << 28 // wait for outstanding writes
+)
+
+// Transfer information bits of DMA control block
+const (
+	dmaWaitResp     = 1 << 3
+	dmaDestInc      = 1 << 4
+	dmaDestDreq     = 1 << 6
+	dmaSrcInc       = 1 << 8
+	dmaNoWideBursts = 1 << 26
+)
+
+// dmaPermap returns transfer information bits of peripheral pacing the transfer
+func dmaPermap(dreq uint32) uint32 {
+	return dreq << 16
+}
+
+// Peripheral addresses as seen by DMA (bus addresses)
+const (
+	busPeripheralBase = 0x7E000000
+	busGpset0         = busPeripheralBase + gpioOffset + 0x1c
+	busGpclr0         = busPeripheralBase + gpioOffset + 0x28
+	busPwmFifo        = busPeripheralBase + pwmOffset + 0x18
+	busPcmFifo        = busPeripheralBase + pcmOffset + 0x04
+)
+
+// Mailbox tags for allocation of GPU memory
+const (
+	mboxAllocateMemory = 0x0003000c
+	mboxLockMemory     = 0x0003000d
+	mboxUnlockMemory   = 0x0003000e
+	mboxReleaseMemory  = 0x0003000f
+)
+
+// DmaControlBlock is a DMA control block as read by the DMA engine (32 bytes)
+type DmaControlBlock struct {
+	TransferInfo uint32
+	Source       uint32 // bus address
+	Dest         uint32 // bus address
+	Length       uint32 // bytes
+	Stride       uint32
+	Next         uint32    // bus address of next control block, 0 to stop
+	Data         [2]uint32 // reserved words, used for data copied by the block itself
+}
+
+const dmaControlBlockSize = 32
+
+// words returns control block as it is laid out in memory
+func (cb DmaControlBlock) words() [8]uint32 {
+	return [8]uint32{cb.TransferInfo, cb.Source, cb.Dest, cb.Length, cb.Stride, cb.Next, cb.Data[0], cb.Data[1]}
+}
+
+// dmaMemory is uncached memory allocated by the GPU, accessible by DMA engines
+type dmaMemory struct {
+	handle  uint32
+	busAddr uint32
+	mem     []uint32
+	mem8    []byte
+}
+
+// allocDmaMemory allocates size bytes of uncached memory through mailbox and maps it
+func allocDmaMemory(size int) (*dmaMemory, error) {
+	const pageSize = 4096
+	size = (size + pageSize - 1) / pageSize * pageSize
+
+	flags := uint32(0x4) // direct, uncached (0xC0000000 alias)
+	if currentChip.SoC == BCM2835 {
+		flags = 0xc // l1 non allocating (0x40000000 alias is coherent with L2)
+	}
+
+	values, err := mailboxProperty(mboxAllocateMemory, uint32(size), pageSize, flags)
+	if err != nil {
+		return nil, err
+	}
+	m := &dmaMemory{handle: values[0]}
+	if m.handle == 0 {
+		return nil, MailboxError
+	}
+
+	values, err = mailboxProperty(mboxLockMemory, m.handle)
+	if err != nil || values[0] == 0 {
+		mailboxProperty(mboxReleaseMemory, m.handle)
+		return nil, MailboxError
+	}
+	m.busAddr = values[0]
+
+	file, err := os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, os.ModePerm)
+	if err != nil {
+		m.free()
+		return nil, err
+	}
+	defer file.Close()
+
+	const busToPhys = 0x3FFFFFFF // strip cache alias bits
+	m.mem, m.mem8, err = memMap(file.Fd(), int64(m.busAddr&busToPhys), size)
+	if err != nil {
+		m.free()
+		return nil, err
+	}
+	return m, nil
+}
+
+// free unmaps and releases the memory
+func (m *dmaMemory) free() {
+	if m.mem8 != nil {
+		syscall.Munmap(m.mem8)
+		m.mem8, m.mem = nil, nil
+	}
+	mailboxProperty(mboxUnlockMemory, m.handle)
+	mailboxProperty(mboxReleaseMemory, m.handle)
+}
+
+// writeControlBlocks copies control blocks to the memory
+func (m *dmaMemory) writeControlBlocks(cbs []DmaControlBlock) {
+	for i, cb := range cbs {
+		words := cb.words()
+		copy(m.mem[i*dmaControlBlockSize/4:], words[:])
+	}
+}
+
+// dmaStart resets the channel and starts processing control blocks from given bus address
+func dmaStart(channel int, cbAddr uint32) {
+	regs := dmaMem[channel*dmaChannelLen:]
+
+	dmaMem[dmaEnableReg] |= 1 << uint(channel)
+	regs[dmaCsReg] = dmaReset
+	DelayMicroseconds(10)
+	regs[dmaCsReg] = dmaInt | dmaEnd // clear flags
+	regs[dmaConblkReg] = cbAddr
+	regs[dmaDebugReg] = 7 // clear errors
+
+	const priority = 8 << 16
+	const panicPriority = 15 << 20
+	regs[dmaCsReg] = dmaWaitWrites | panicPriority | priority | dmaActive
+}
+
+// dmaActiveChannel checks whether channel is processing control blocks
+func dmaActiveChannel(channel int) bool {
+	return dmaMem[channel*dmaChannelLen+dmaCsReg]&dmaActive != 0
+}
+
+// dmaStop aborts transfer of channel
+func dmaStop(channel int) {
+	regs := dmaMem[channel*dmaChannelLen:]
+	regs[dmaCsReg] = dmaAbort
+	DelayMicroseconds(10)
+	regs[dmaCsReg] = dmaReset
+}
@@ -0,0 +1,175 @@
+package rpio
+
+import (
+	"errors"
+	"time"
+)
+
+// DS18B20 function commands
+const (
+	ds18b20Convert        = 0x44
+	ds18b20ReadScratchpad = 0xbe
+	ds18b20Write          = 0x4e
+	ds18b20ReadPower      = 0xb4 // parasite powered devices reply 0
+	ds18b20Family         = 0x28
+)
+
+var (
+	DS18B20ResolutionError = errors.New("DS18B20 resolution must be 9 - 12 bits")
+)
+
+// DS18B20 is 1-Wire digital thermometer.
+//
+// Parasite powered thermometers (VDD grounded) need a strong pull-up during conversion,
+// the pin is then driven High instead of polling the end of conversion. This works when the Pin
+// is connected to the bus directly, the pull-up resistor alone does not supply enough current.
+type DS18B20 struct {
+	bus        *OneWire
+	address    OneWireAddress
+	resolution int
+}
+
+// NewDS18B20 creates driver of the thermometer with given address (see OneWire.Search),
+// the resolution is assumed to be the power-on default of 12 bits.
+func NewDS18B20(bus *OneWire, address OneWireAddress) *DS18B20 {
+	return &DS18B20{bus: bus, address: address, resolution: 12}
+}
+
+// FindDS18B20 finds all thermometers on the bus
+func FindDS18B20(bus *OneWire) ([]*DS18B20, error) {
+	addresses, err := bus.Search()
+	var sensors []*DS18B20
+	for _, address := range addresses {
+		if address.Family() == ds18b20Family {
+			sensors = append(sensors, NewDS18B20(bus, address))
+		}
+	}
+	return sensors, err
+}
+
+// Address returns address of the thermometer
+func (d *DS18B20) Address() OneWireAddress {
+	return d.address
+}
+
+// SetResolution sets resolution of measurement, 9 (0.5°C, 94ms conversion) - 12 bits (0.0625°C, 750ms).
+// The setting is not stored to EEPROM, it is lost after power cycle. Alarm thresholds (TH, TL) are kept.
+func (d *DS18B20) SetResolution(bits int) error {
+	if bits < 9 || bits > 12 {
+		return DS18B20ResolutionError
+	}
+	scratchpad, err := d.readScratchpad()
+	if err != nil {
+		return err
+	}
+	if err := d.bus.Select(d.address); err != nil {
+		return err
+	}
+	config := byte(bits-9)<<5 | 0x1f
+	d.bus.Write([]byte{ds18b20Write, scratchpad[2], scratchpad[3], config})
+	d.resolution = bits
+	return nil
+}
+
+// ConversionTime returns maximal duration of temperature conversion at current resolution
+func (d *DS18B20) ConversionTime() time.Duration {
+	return ds18b20ConversionTime(d.resolution)
+}
+
+func ds18b20ConversionTime(bits int) time.Duration {
+	return 750 * time.Millisecond >> uint(12-bits)
+}
+
+// Convert starts temperature conversion and waits for its end
+func (d *DS18B20) Convert() error {
+	parasite, err := startConversion(d.bus, func() error { return d.bus.Select(d.address) })
+	if err != nil {
+		return err
+	}
+	return waitConversion(d.bus, d.ConversionTime(), parasite)
+}
+
+// ConvertAll starts temperature conversion of all thermometers on the bus at once
+// and waits for its end (at most 750ms for 12 bit resolution). Then read them by ReadTemperature.
+func ConvertAll(bus *OneWire) error {
+	parasite, err := startConversion(bus, bus.Skip)
+	if err != nil {
+		return err
+	}
+	return waitConversion(bus, ds18b20ConversionTime(12), parasite)
+}
+
+// startConversion checks whether any of addressed thermometers is parasite powered (Read Power Supply)
+// and starts the conversion
+func startConversion(bus *OneWire, address func() error) (parasite bool, err error) {
+	if err := address(); err != nil {
+		return false, err
+	}
+	bus.Write([]byte{ds18b20ReadPower})
+	parasite = bus.ReadBit() == 0
+
+	if err := address(); err != nil {
+		return false, err
+	}
+	bus.Write([]byte{ds18b20Convert})
+	return parasite, nil
+}
+
+// waitConversion reads time slots until the conversion is done (1 is read).
+// Parasite powered thermometers can not be polled, the bus is driven High for the whole conversion time.
+func waitConversion(bus *OneWire, timeout time.Duration, parasite bool) error {
+	if parasite {
+		bus.pin.Write(High) // strong pull-up
+		bus.pin.Output()
+		bus.delay(uint32(timeout / time.Microsecond))
+		bus.release()
+		return nil
+	}
+	for waited := time.Duration(0); waited < timeout+timeout/4; waited += 10 * time.Millisecond {
+		if bus.ReadBit() == 1 {
+			return nil
+		}
+		bus.delay(10000)
+	}
+	return TimeoutError
+}
+
+// Temperature starts conversion and reads the temperature [°C]
+func (d *DS18B20) Temperature() (float64, error) {
+	if err := d.Convert(); err != nil {
+		return 0, err
+	}
+	return d.ReadTemperature()
+}
+
+// ReadTemperature reads the result of last conversion [°C], see Convert and ConvertAll.
+// Note that 85°C is the power-on value, read before any conversion.
+func (d *DS18B20) ReadTemperature() (float64, error) {
+	scratchpad, err := d.readScratchpad()
+	if err != nil {
+		return 0, err
+	}
+	return ds18b20Temperature(scratchpad), nil
+}
+
+func (d *DS18B20) readScratchpad() ([]byte, error) {
+	if err := d.bus.Select(d.address); err != nil {
+		return nil, err
+	}
+	d.bus.Write([]byte{ds18b20ReadScratchpad})
+	scratchpad := make([]byte, 9)
+	d.bus.Read(scratchpad)
+
+	if OneWireCRC8(scratchpad) != 0 || scratchpad[4]&0x9f != 0x1f { // all bits 1 on missing device
+		return nil, OneWireCrcError
+	}
+	return scratchpad, nil
+}
+
+// ds18b20Temperature decodes temperature from scratchpad, undefined bits of lower resolutions are dropped
+func ds18b20Temperature(scratchpad []byte) float64 {
+	raw := int16(uint16(scratchpad[1])<<8 | uint16(scratchpad[0]))
+	bits := int(scratchpad[4]>>5&3) + 9
+	raw &^= 1<<uint(12-bits) - 1
+	return float64(raw) / 16
+}
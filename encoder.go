@@ -0,0 +1,321 @@
+package rpio
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// EncoderMode selects how many counts per Gray code cycle (4 transitions) the encoder reports
+type EncoderMode uint8
+
+const (
+	EncoderX1 EncoderMode = iota // count once per cycle, at rest (detent) state
+	EncoderX2                    // count twice per cycle, at rest state and its opposite
+	EncoderX4                    // count every transition
+)
+
+// encoderSteps maps transition (old state << 2 | new state) to quarter steps,
+// state is level of pin A << 1 | level of pin B, forward sequence is 00 -> 10 -> 11 -> 01 -> 00.
+// Transitions changing both pins are invalid (0 with invalid flag).
+var encoderSteps = [16]int8{
+	0b0000: 0, 0b0010: +1, 0b0001: -1, 0b0011: 0,
+	0b1011: +1, 0b1000: -1, 0b1010: 0, 0b1001: 0,
+	0b1101: +1, 0b1110: -1, 0b1111: 0, 0b1100: 0,
+	0b0100: +1, 0b0111: -1, 0b0101: 0, 0b0110: 0,
+}
+
+// encoderInvalid checks whether transition changes both pins
+func encoderInvalid(old, new uint8) bool {
+	return old^new == 3
+}
+
+// EncoderEvent is a change of encoder position
+type EncoderEvent struct {
+	Position  int
+	Direction int // +1 forward (A leads B), -1 backward
+	Time      time.Duration
+}
+
+// Encoder decodes quadrature rotary encoder connected to pins A and B.
+//
+// Changes of position are sent to the Events channel, events are dropped if they are not consumed.
+type Encoder struct {
+	a, b   Pin
+	mode   EncoderMode
+	button *Button
+	events chan EncoderEvent
+
+	mu        sync.Mutex
+	rest      uint8 // state at detent, the encoder is assumed to rest there when created
+	state     uint8
+	acc       int // quarter steps since last count
+	position  int
+	direction int
+	invalid   int
+	counted   bool
+	detect    bool          // edge detection enabled by poll
+	lastTime  time.Duration // of last count
+	interval  time.Duration // between last two counts
+}
+
+// NewEncoder: Sets pins A and B to Input mode with pull up (encoder switches to ground).
+// Call Run to start decoding.
+func NewEncoder(a, b Pin, mode EncoderMode) *Encoder {
+	for _, pin := range []Pin{a, b} {
+		pin.Input()
+		pin.PullUp()
+	}
+	return newEncoder(a, b, mode, ReadPin(a), ReadPin(b))
+}
+
+func newEncoder(a, b Pin, mode EncoderMode, levelA, levelB State) *Encoder {
+	state := uint8(levelA)<<1 | uint8(levelB)
+	return &Encoder{
+		a:      a,
+		b:      b,
+		mode:   mode,
+		events: make(chan EncoderEvent, 64),
+		rest:   state,
+		state:  state,
+	}
+}
+
+// AttachButton adds push button of the encoder, it is polled by Run
+func (e *Encoder) AttachButton(pin Pin, config ButtonConfig) *Button {
+	e.button = NewButton(pin, config)
+	return e.button
+}
+
+// Events returns channel of position changes
+func (e *Encoder) Events() <-chan EncoderEvent {
+	return e.events
+}
+
+// Position returns number of counts, positive in forward direction
+func (e *Encoder) Position() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.position
+}
+
+// SetPosition sets current position, eg. to zero
+func (e *Encoder) SetPosition(position int) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.position = position
+}
+
+// Direction returns direction of the last count, +1 forward, -1 backward, 0 if not moved yet
+func (e *Encoder) Direction() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.direction
+}
+
+// Invalid returns number of rejected transitions (both pins changed at once, eg. a missed edge)
+func (e *Encoder) Invalid() int {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.invalid
+}
+
+// Velocity returns speed [counts/s] measured between last two counts, signed by direction.
+// It is zero when the encoder does not move for a second or longer than the last interval.
+func (e *Encoder) Velocity() float64 {
+	return e.velocity(systemTime())
+}
+
+func (e *Encoder) velocity(now time.Duration) float64 {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	idle := now - e.lastTime
+	if e.interval == 0 || idle > time.Second || idle > 2*e.interval {
+		return 0
+	}
+	return float64(e.direction) * float64(time.Second) / float64(e.interval)
+}
+
+// Add processes edge event of pin A or B, from any edge source (eg. WatchEdges)
+func (e *Encoder) Add(event EdgeEvent) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	state := e.state
+	switch event.Pin {
+	case e.a:
+		state = state&^2 | uint8(event.Level)<<1
+	case e.b:
+		state = state&^1 | uint8(event.Level)
+	default:
+		return
+	}
+	e.update(state, event.Time)
+}
+
+// update processes new state of both pins
+func (e *Encoder) update(state uint8, now time.Duration) {
+	old := e.state
+	if state == old {
+		return
+	}
+	e.state = state
+	if encoderInvalid(old, state) {
+		e.invalid++
+		e.acc = 0
+		return
+	}
+	e.acc += int(encoderSteps[old<<2|state])
+
+	var div int
+	switch e.mode {
+	case EncoderX1:
+		if state != e.rest {
+			return
+		}
+		div = 4
+	case EncoderX2:
+		if state != e.rest && state != e.rest^3 {
+			return
+		}
+		div = 2
+	default:
+		div = 1
+	}
+
+	counts := e.acc / div
+	e.acc = 0
+	if counts == 0 {
+		return
+	}
+
+	e.position += counts
+	e.direction = 1
+	if counts < 0 {
+		e.direction = -1
+	}
+	if e.counted {
+		e.interval = now - e.lastTime
+	}
+	e.counted = true
+	e.lastTime = now
+
+	select {
+	case e.events <- EncoderEvent{Position: e.position, Direction: e.direction, Time: now}:
+	default:
+	}
+}
+
+// Run: Decodes the encoder until stop is closed. Blocks, so run it in a goroutine.
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available, so no edge is lost
+// while the program is not scheduled. Otherwise pins are polled every 250µs and read only
+// when an edge was detected on them. The button is polled every millisecond.
+func (e *Encoder) Run(stop <-chan struct{}) {
+	fileA, err := openLineEvents(e.a, AnyEdge, "rpio-encoder")
+	if err != nil {
+		e.poll(stop)
+		return
+	}
+	fileB, err := openLineEvents(e.b, AnyEdge, "rpio-encoder")
+	if err != nil {
+		fileA.Close()
+		e.poll(stop)
+		return
+	}
+	defer fileA.Close()
+	defer fileB.Close()
+
+	edgesA, edgesB := lineEdges(e.a, fileA, stop), lineEdges(e.b, fileB, stop)
+	ticker := time.NewTicker(time.Millisecond)
+	defer ticker.Stop()
+	for {
+		var event EdgeEvent
+		var ok bool
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			e.updateButton(systemTime())
+			continue
+		case event, ok = <-edgesA:
+		case event, ok = <-edgesB:
+		}
+		if !ok {
+			return
+		}
+		for _, event := range pendingEdges([]EdgeEvent{event}, edgesA, edgesB) {
+			e.Add(event)
+		}
+	}
+}
+
+// pendingEdges appends events already queued in channels and sorts them by time,
+// as events of the pins are read separately and can arrive out of order
+func pendingEdges(events []EdgeEvent, channels ...<-chan EdgeEvent) []EdgeEvent {
+	for _, c := range channels {
+	drain:
+		for {
+			select {
+			case event, ok := <-c:
+				if !ok {
+					break drain
+				}
+				events = append(events, event)
+			default:
+				break drain
+			}
+		}
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Time < events[j].Time })
+	return events
+}
+
+// poll decodes the encoder by edge detection status of the pins until stop is closed.
+// Edge detection is enabled only here, it masks gpio interrupts used by kernel line events.
+func (e *Encoder) poll(stop <-chan struct{}) {
+	e.mu.Lock()
+	e.a.Detect(AnyEdge)
+	e.b.Detect(AnyEdge)
+	e.detect = true
+	e.mu.Unlock()
+
+	ticker := time.NewTicker(250 * time.Microsecond)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		now := systemTime()
+		edgeA, edgeB := EdgeDetected(e.a), EdgeDetected(e.b) // both read to clear their flags
+		if edgeA || edgeB {
+			state := uint8(ReadPin(e.a))<<1 | uint8(ReadPin(e.b))
+			e.mu.Lock()
+			e.update(state, now)
+			e.mu.Unlock()
+		}
+		e.updateButton(now)
+	}
+}
+
+func (e *Encoder) updateButton(now time.Duration) {
+	if e.button != nil {
+		e.button.pin.Poll()
+		e.button.Update(now)
+	}
+}
+
+// Close disables edge detection on encoder pins, if it was enabled by Run
+func (e *Encoder) Close() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if e.detect {
+		e.a.Detect(NoEdge)
+		e.b.Detect(NoEdge)
+		e.detect = false
+	}
+}
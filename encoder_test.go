@@ -0,0 +1,99 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+// feedEncoder turns encoder by given quarter steps, one edge per millisecond
+func feedEncoder(e *Encoder, quarters int, now *time.Duration) {
+	forward := []uint8{0, 2, 3, 1} // 00 -> 10 -> 11 -> 01
+	index := map[uint8]int{0: 0, 2: 1, 3: 2, 1: 3}
+	for ; quarters != 0; *now += time.Millisecond {
+		i := index[e.state]
+		if quarters > 0 {
+			i, quarters = (i+1)%4, quarters-1
+		} else {
+			i, quarters = (i+3)%4, quarters+1
+		}
+		next := forward[i]
+		pin, level := e.b, State(next&1)
+		if (next^e.state)&2 != 0 {
+			pin, level = e.a, State(next>>1)
+		}
+		e.Add(EdgeEvent{Pin: pin, Level: level, Time: *now})
+	}
+}
+
+func TestEncoderModes(t *testing.T) {
+	tests := []struct {
+		mode  EncoderMode
+		moves []int
+		want  int
+	}{
+		{EncoderX4, []int{8}, 8},
+		{EncoderX2, []int{8}, 4},
+		{EncoderX1, []int{8}, 2},
+		{EncoderX1, []int{-12}, -3},
+		{EncoderX1, []int{1, -1, 1, -1, 3}, 0}, // jitter around detent and incomplete cycle
+		{EncoderX1, []int{2, -2, 4}, 1},
+		{EncoderX4, []int{5, -7}, -2},
+	}
+	for _, test := range tests {
+		for _, rest := range []State{Low, High} {
+			e := newEncoder(23, 24, test.mode, rest, rest)
+			var now time.Duration
+			for _, move := range test.moves {
+				feedEncoder(e, move, &now)
+			}
+			if p := e.Position(); p != test.want {
+				t.Errorf("mode %d, moves %v, rest %d: position %d, want %d", test.mode, test.moves, rest, p, test.want)
+			}
+		}
+	}
+}
+
+func TestEncoderInvalidAndVelocity(t *testing.T) {
+	e := newEncoder(23, 24, EncoderX4, Low, Low)
+	var now time.Duration
+	feedEncoder(e, 3, &now)
+
+	e.update(2, now) // both pins change at once, 01 -> 10
+	if e.Position() != 3 || e.Invalid() != 1 {
+		t.Errorf("after invalid transition: position %d, invalid %d", e.Position(), e.Invalid())
+	}
+	feedEncoder(e, 1, &now) // continues from 10
+	if e.Position() != 4 {
+		t.Errorf("position %d, want 4", e.Position())
+	}
+
+	e = newEncoder(23, 24, EncoderX4, Low, Low)
+	now = 0
+	feedEncoder(e, -4, &now) // counts every millisecond
+	if v := e.velocity(now); v != -1000 {
+		t.Errorf("velocity %v, want -1000", v)
+	}
+	if d := e.Direction(); d != -1 {
+		t.Errorf("direction %d, want -1", d)
+	}
+	if v := e.velocity(now + time.Second); v != 0 {
+		t.Errorf("velocity after stop %v, want 0", v)
+	}
+}
+
+func TestEncoderPendingEdges(t *testing.T) {
+	e := newEncoder(23, 24, EncoderX4, Low, Low)
+	a, b := make(chan EdgeEvent, 4), make(chan EdgeEvent, 4)
+	// forward cycle 00 -> 10 -> 11 -> 01 -> 00, edges of A queued before those of B
+	a <- EdgeEvent{Pin: 23, Level: Low, Time: 4}
+	b <- EdgeEvent{Pin: 24, Level: High, Time: 2}
+	b <- EdgeEvent{Pin: 24, Level: Low, Time: 5}
+	close(b)
+
+	for _, event := range pendingEdges([]EdgeEvent{{Pin: 23, Level: High, Time: 1}}, a, b) {
+		e.Add(event)
+	}
+	if e.Position() != 4 || e.Invalid() != 0 {
+		t.Errorf("position %d, invalid %d, want 4, 0", e.Position(), e.Invalid())
+	}
+}
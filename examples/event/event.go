@@ -34,16 +34,23 @@ func main() {
 
 	pin.Input()
 	pin.PullUp()
-	pin.Detect(rpio.FallEdge) // enable falling edge event detection
+	pin.Detect(rpio.AnyEdge) // enable edge event detection, catches bounces between polls
+
+	button := rpio.NewDebouncedPin(pin, 20*time.Millisecond, rpio.Low)
 
 	fmt.Println("press a button")
 
 	for i := 0; i < 2; {
-		if pin.EdgeDetected() { // check if event occured
-			fmt.Println("button pressed")
-			i++
+		button.Poll()
+		select {
+		case event := <-button.Events(): // check if button state changed
+			if event.Pressed {
+				fmt.Println("button pressed")
+				i++
+			}
+		default:
+			time.Sleep(time.Millisecond)
 		}
-		time.Sleep(time.Second / 2)
 	}
 	pin.Detect(rpio.NoEdge) // disable edge event detection
 }
@@ -0,0 +1,195 @@
+package rpio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Kernel GPIO character device (/dev/gpiochipN), uapi v1.
+// Edge events are timestamped and queued by the kernel, so they are not lost between reads.
+
+var GpioChipError = errors.New("gpio character device not found")
+
+// ioctl numbers, _IOR/_IOWR(0xB4, nr, size)
+const (
+	gpioGetChipInfoIoctl  = 2<<30 | 68<<16 | 0xb4<<8 | 0x01 // struct gpiochip_info
+	gpioGetLineEventIoctl = 3<<30 | 48<<16 | 0xb4<<8 | 0x04 // struct gpioevent_request
+)
+
+const (
+	gpioHandleRequestInput = 1 << 0
+	gpioEventRisingEdge    = 1 // id of gpioevent_data
+	gpioEventDataSize      = 16
+)
+
+// gpioEventRequest is struct gpioevent_request
+type gpioEventRequest struct {
+	lineOffset   uint32
+	handleFlags  uint32
+	eventFlags   uint32
+	consumerName [32]byte
+	fd           int32
+}
+
+// gpioChipInfo is struct gpiochip_info
+type gpioChipInfo struct {
+	name  [32]byte
+	label [32]byte
+	lines uint32
+}
+
+// lineEvent is an edge event reported by the kernel
+type lineEvent struct {
+	Rising bool
+	Time   time.Duration // converted to the system timer base, see lineEventTime
+}
+
+// findGpioChip finds gpio chip of the SoC pin controller (pinctrl-bcm2835, pinctrl-bcm2711, pinctrl-rp1)
+func findGpioChip() (string, error) {
+	chip, err := detectChip() // also before Open
+	if err != nil {
+		chip = currentChip
+	}
+	rp1 := chip.SoC == BCM2712
+
+	paths, _ := filepath.Glob("/dev/gpiochip*")
+	for _, path := range paths {
+		file, err := os.Open(path)
+		if err != nil {
+			continue
+		}
+		var info gpioChipInfo
+		_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), gpioGetChipInfoIoctl, uintptr(unsafe.Pointer(&info)))
+		file.Close()
+		if errno != 0 {
+			continue
+		}
+		label := string(bytes.TrimRight(info.label[:], "\x00"))
+		if strings.HasPrefix(label, "pinctrl-") && strings.Contains(label, "rp1") == rp1 {
+			return path, nil
+		}
+	}
+	return "", GpioChipError
+}
+
+// openLineEvents requests edge events of given pin (RiseEdge, FallEdge or AnyEdge) from the kernel.
+// The pin is configured as input and cannot be used by another process until the file is closed.
+func openLineEvents(pin Pin, edge Edge, consumer string) (*os.File, error) {
+	path, err := findGpioChip()
+	if err != nil {
+		return nil, err
+	}
+	chip, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer chip.Close()
+
+	req := gpioEventRequest{
+		lineOffset:  uint32(pin),
+		handleFlags: gpioHandleRequestInput,
+		eventFlags:  uint32(edge), // same bits as GPIOEVENT_REQUEST_RISING_EDGE / FALLING_EDGE
+	}
+	copy(req.consumerName[:len(req.consumerName)-1], consumer)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, chip.Fd(), gpioGetLineEventIoctl, uintptr(unsafe.Pointer(&req)))
+	if errno != 0 {
+		return nil, errno
+	}
+
+	// non blocking fd is handled by the runtime poller, so Close interrupts pending Read
+	if err := syscall.SetNonblock(int(req.fd), true); err != nil {
+		syscall.Close(int(req.fd))
+		return nil, err
+	}
+	return os.NewFile(uintptr(req.fd), path), nil
+}
+
+// readLineEvents reads events until the file is closed
+func readLineEvents(file *os.File, handle func(lineEvent)) error {
+	buf := make([]byte, gpioEventDataSize*64)
+	for {
+		n, err := file.Read(buf)
+		if err != nil {
+			return err
+		}
+		monotonic, realtime, system := clockTime(clockMonotonic), clockTime(clockRealtime), systemTime()
+		for i := 0; i+gpioEventDataSize <= n; i += gpioEventDataSize {
+			event := parseLineEvent(buf[i : i+gpioEventDataSize])
+			event.Time = lineEventTime(event.Time, monotonic, realtime, system)
+			handle(event)
+		}
+	}
+}
+
+// watchLineEdges: Sends level changes of input pin to the returned channel.
+// Kernel line events are used when available (the returned file has to be closed when done),
+// otherwise the pin is polled until stop is closed, see WatchEdges.
+func watchLineEdges(pin Pin, consumer string, stop <-chan struct{}) (<-chan EdgeEvent, *os.File, error) {
+	file, err := openLineEvents(pin, AnyEdge, consumer)
+	if err != nil {
+		if gpioMem == nil && rp1Mem == nil {
+			return nil, nil, err
+		}
+		pin.Input()
+		return WatchEdges(pin, stop), nil, nil
+	}
+	return lineEdges(pin, file, stop), file, nil
+}
+
+// lineEdges sends kernel line events read from file to the returned channel, until the file is closed
+func lineEdges(pin Pin, file *os.File, stop <-chan struct{}) <-chan EdgeEvent {
+	edges := make(chan EdgeEvent, 1024)
+	go func() {
+		defer close(edges)
+		readLineEvents(file, func(event lineEvent) {
+			level := Low
+			if event.Rising {
+				level = High
+			}
+			select {
+			case edges <- EdgeEvent{Pin: pin, Level: level, Time: event.Time}:
+			case <-stop:
+			}
+		})
+	}()
+	return edges
+}
+
+// parseLineEvent decodes struct gpioevent_data {u64 timestamp; u32 id}
+func parseLineEvent(data []byte) lineEvent {
+	return lineEvent{
+		Time:   time.Duration(binary.LittleEndian.Uint64(data)),
+		Rising: binary.LittleEndian.Uint32(data[8:]) == gpioEventRisingEdge,
+	}
+}
+
+const (
+	clockRealtime  = 0
+	clockMonotonic = 1
+)
+
+// clockTime returns time of given clock (clock_gettime)
+func clockTime(clock uintptr) time.Duration {
+	var ts syscall.Timespec
+	syscall.Syscall(syscall.SYS_CLOCK_GETTIME, clock, uintptr(unsafe.Pointer(&ts)), 0)
+	return time.Duration(ts.Nano())
+}
+
+// lineEventTime converts timestamp of kernel line event to the system timer base (see SystemTime),
+// given current time of the clocks. Kernels since 5.7 timestamp the events by CLOCK_MONOTONIC,
+// older ones by CLOCK_REALTIME, the clock is recognized by which one is closer to the timestamp.
+func lineEventTime(timestamp, monotonic, realtime, system time.Duration) time.Duration {
+	age := monotonic - timestamp
+	if diff := realtime - timestamp; diff >= 0 && (age < 0 || diff < age) {
+		age = diff
+	}
+	return system - age
+}
@@ -0,0 +1,24 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLineEventTime(t *testing.T) {
+	s := time.Second
+	monotonic, realtime, system := 100*s, 1600000000*s, 50*s
+	for _, c := range []struct {
+		name      string
+		timestamp time.Duration
+		want      time.Duration
+	}{
+		{"monotonic", monotonic - 3*time.Millisecond, system - 3*time.Millisecond},
+		{"realtime, before 5.7", realtime - 3*time.Millisecond, system - 3*time.Millisecond},
+		{"monotonic, read now", monotonic, system},
+	} {
+		if got := lineEventTime(c.timestamp, monotonic, realtime, system); got != c.want {
+			t.Errorf("%s: %v, want %v", c.name, got, c.want)
+		}
+	}
+}
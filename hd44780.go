@@ -0,0 +1,295 @@
+package rpio
+
+import (
+	"errors"
+	"sync"
+)
+
+// HD44780 instructions and their flags
+const (
+	hd44780Clear       = 0x01
+	hd44780Home        = 0x02
+	hd44780EntryMode   = 0x04 // | increment 0x02 | shift display 0x01
+	hd44780Display     = 0x08 // | display on 0x04 | cursor 0x02 | blink 0x01
+	hd44780Shift       = 0x10 // | display (not cursor) 0x08 | right 0x04
+	hd44780FunctionSet = 0x20 // | 8-bit 0x10 | 2 lines 0x08 | 5x10 font 0x04
+	hd44780CGRAM       = 0x40 // | address
+	hd44780DDRAM       = 0x80 // | address
+)
+
+const (
+	hd44780DisplayOn = 0x04
+	hd44780CursorOn  = 0x02
+	hd44780BlinkOn   = 0x01
+)
+
+var HD44780ConfigError = errors.New("HD44780 needs 4 or 8 data pins")
+
+// HD44780Config: Wiring and size of the display, zero size means 16x2
+type HD44780Config struct {
+	RS, E     DigitalPin
+	RW        DigitalPin   // optional, busy flag is polled when connected, otherwise worst case delays are used
+	Data      []DigitalPin // D4 - D7 in 4-bit mode, D0 - D7 in 8-bit mode
+	Backlight DigitalPin   // optional, backlight is on when High
+	Columns   int
+	Rows      int
+}
+
+// HD44780 is a driver of character LCD with HD44780 (or compatible) controller,
+// connected directly to pins in 4-bit or 8-bit mode.
+//
+// Note that the busy flag can be read only when the display is powered by 3.3V,
+// or its data lines are level shifted. Otherwise leave RW grounded and nil.
+//
+// Text is written by Write (the display is an io.Writer), '\n' moves to the start of next row.
+// Characters 0 - 7 are the custom ones, see CreateChar.
+type HD44780 struct {
+	config  HD44780Config
+	control byte // display on, cursor, blink
+	col     int
+	row     int
+
+	delay func(us uint32)
+	mu    sync.Mutex
+}
+
+// NewHD44780 creates driver and sets its pins to Output, call Init then
+func NewHD44780(config HD44780Config) (*HD44780, error) {
+	if len(config.Data) != 4 && len(config.Data) != 8 {
+		return nil, HD44780ConfigError
+	}
+	if config.Columns == 0 || config.Rows == 0 {
+		config.Columns, config.Rows = 16, 2
+	}
+
+	pins := append([]DigitalPin{config.RS, config.E}, config.Data...)
+	if config.RW != nil {
+		pins = append(pins, config.RW)
+	}
+	for _, pin := range pins {
+		pin.Write(Low)
+		pin.Output()
+	}
+	if config.Backlight != nil {
+		config.Backlight.Write(High)
+		config.Backlight.Output()
+	}
+	return &HD44780{config: config, delay: DelayMicroseconds}, nil
+}
+
+// Init: Initializes the controller by instruction (so it works also after improper power-up),
+// turns display on without cursor, clears it and sets left to right entry.
+func (d *HD44780) Init() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.delay(50000) // power on, Vcc rising to 2.7V
+
+	// the controller may be in 8-bit mode or in the middle of 4-bit transfer,
+	// 3 times function set in 8-bit mode gets it into a known state
+	d.config.RS.Write(Low)
+	for _, wait := range []uint32{4100, 100, 100} {
+		d.writeBits(0x30)
+		d.delay(wait)
+	}
+	function := byte(hd44780FunctionSet)
+	if len(d.config.Data) == 8 {
+		function |= 0x10
+	} else {
+		d.writeBits(0x20) // switch to 4-bit mode, still an 8-bit transfer
+		d.delay(100)
+	}
+	if d.config.Rows > 1 {
+		function |= 0x08
+	}
+
+	d.command(function)
+	d.control = hd44780DisplayOn
+	d.command(hd44780Display) // off while clearing
+	d.command(hd44780Clear)
+	d.command(hd44780EntryMode | 0x02)
+	d.command(hd44780Display | d.control)
+	d.col, d.row = 0, 0
+}
+
+// Clear clears the display and moves cursor home
+func (d *HD44780) Clear() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.command(hd44780Clear)
+	d.col, d.row = 0, 0
+}
+
+// Home moves cursor to the top left and cancels display shift
+func (d *HD44780) Home() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.command(hd44780Home)
+	d.col, d.row = 0, 0
+}
+
+// SetCursor moves cursor to given column and row, numbered from 0
+func (d *HD44780) SetCursor(col, row int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.setCursor(col, row)
+}
+
+func (d *HD44780) setCursor(col, row int) {
+	// rows 2 and 3 continue rows 0 and 1 in DDRAM
+	offsets := [4]int{0x00, 0x40, d.config.Columns, 0x40 + d.config.Columns}
+	d.col, d.row = col, row%4
+	d.command(hd44780DDRAM | byte(offsets[d.row]+col))
+}
+
+// Display turns the display on or off, content is kept
+func (d *HD44780) Display(on bool) {
+	d.setControl(hd44780DisplayOn, on)
+}
+
+// Cursor shows or hides underline cursor
+func (d *HD44780) Cursor(on bool) {
+	d.setControl(hd44780CursorOn, on)
+}
+
+// Blink turns blinking block cursor on or off
+func (d *HD44780) Blink(on bool) {
+	d.setControl(hd44780BlinkOn, on)
+}
+
+func (d *HD44780) setControl(flag byte, on bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if on {
+		d.control |= flag
+	} else {
+		d.control &^= flag
+	}
+	d.command(hd44780Display | d.control)
+}
+
+// Scroll shifts the whole display by given number of positions, to the right when positive
+func (d *HD44780) Scroll(positions int) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	instruction := byte(hd44780Shift | 0x08 | 0x04)
+	if positions < 0 {
+		instruction, positions = hd44780Shift|0x08, -positions
+	}
+	for i := 0; i < positions; i++ {
+		d.command(instruction)
+	}
+}
+
+// Backlight turns backlight on or off, if its pin is configured
+func (d *HD44780) Backlight(on bool) {
+	if d.config.Backlight == nil {
+		return
+	}
+	if on {
+		d.config.Backlight.Write(High)
+	} else {
+		d.config.Backlight.Write(Low)
+	}
+}
+
+// CreateChar defines custom character 0 - 7 by 8 rows of 5 pixels (bit 4 is the leftmost),
+// the cursor position is kept.
+func (d *HD44780) CreateChar(location byte, pattern [8]byte) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.command(hd44780CGRAM | (location&7)<<3)
+	for _, row := range pattern {
+		d.data(row & 0x1f)
+	}
+	d.setCursor(d.col, d.row) // back to DDRAM
+}
+
+// Write writes text at cursor position, text continues on next row at the end of row.
+// Implements io.Writer.
+func (d *HD44780) Write(p []byte) (int, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for _, c := range p {
+		if c == '\n' {
+			d.setCursor(0, (d.row+1)%d.config.Rows)
+			continue
+		}
+		if d.col >= d.config.Columns {
+			d.setCursor(0, (d.row+1)%d.config.Rows)
+		}
+		d.data(c)
+		d.col++
+	}
+	return len(p), nil
+}
+
+func (d *HD44780) command(value byte) {
+	d.send(value, Low)
+}
+
+func (d *HD44780) data(value byte) {
+	d.send(value, High)
+}
+
+// send transfers byte to instruction (rs Low) or data (rs High) register and waits until it is executed
+func (d *HD44780) send(value byte, rs State) {
+	d.config.RS.Write(rs)
+	if len(d.config.Data) == 8 {
+		d.writeBits(value)
+	} else {
+		d.writeBits(value)
+		d.writeBits(value << 4)
+	}
+
+	if d.config.RW != nil {
+		d.waitBusy()
+	} else if rs == Low && value <= hd44780Home {
+		d.delay(1600) // clear and home
+	} else {
+		d.delay(50)
+	}
+}
+
+// writeBits sets data pins to the highest bits of value and latches them by E pulse
+func (d *HD44780) writeBits(value byte) {
+	shift := uint(8 - len(d.config.Data))
+	for i, pin := range d.config.Data {
+		pin.Write(State(value >> (uint(i) + shift) & 1))
+	}
+	d.config.E.Write(High)
+	d.delay(1) // pulse width min 450ns
+	d.config.E.Write(Low)
+	d.delay(1) // cycle time min 1µs
+}
+
+// waitBusy polls busy flag until the last instruction is executed
+func (d *HD44780) waitBusy() {
+	data := d.config.Data
+	for _, pin := range data {
+		pin.Input()
+	}
+	d.config.RS.Write(Low)
+	d.config.RW.Write(High)
+
+	busy := true
+	for tries := 0; busy && tries < 1000; tries++ { // give up after few ms, eg. if not connected
+		d.config.E.Write(High)
+		d.delay(1) // data delay max 360ns
+		busy = data[len(data)-1].Read() == High
+		d.config.E.Write(Low)
+		d.delay(1)
+		if len(data) == 4 { // low nibble of address counter
+			d.config.E.Write(High)
+			d.delay(1)
+			d.config.E.Write(Low)
+			d.delay(1)
+		}
+	}
+
+	d.config.RW.Write(Low)
+	for _, pin := range data {
+		pin.Output()
+	}
+}
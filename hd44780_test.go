@@ -0,0 +1,260 @@
+package rpio
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// lcdSim simulates HD44780 controller, in virtual time advanced by the driver delays
+type lcdSim struct {
+	now       time.Duration
+	busyUntil time.Duration
+	rs, rw, e State
+	data      [8]State // D0 - D7 driven by the driver
+	input     [8]bool  // data pin switched to input
+
+	eightBit  bool
+	pending   int // high nibble received in 4-bit mode, -1 if none
+	readPhase int // nibble of busy flag read in 4-bit mode
+	reset     bool
+	busyReads int
+
+	function, display byte
+	addr              int
+	cgram             bool
+	ddram             [128]byte
+	cgramData         [64]byte
+	errors            []string
+}
+
+func newLcdSim() *lcdSim {
+	return &lcdSim{eightBit: true, pending: -1, reset: true}
+}
+
+func (s *lcdSim) delay(us uint32) {
+	s.now += time.Duration(us) * time.Microsecond
+}
+
+// lcdSimPin is connected to signal of the simulated controller, data line 0 - 7, or -1 RS, -2 RW, -3 E
+type lcdSimPin struct {
+	sim  *lcdSim
+	line int
+}
+
+func (p *lcdSimPin) Output() {
+	if p.line >= 0 {
+		p.sim.input[p.line] = false
+	}
+}
+
+func (p *lcdSimPin) Input() {
+	if p.line >= 0 {
+		p.sim.input[p.line] = true
+	}
+}
+
+func (p *lcdSimPin) Write(state State) {
+	s := p.sim
+	switch p.line {
+	case -1:
+		s.rs = state
+	case -2:
+		s.rw = state
+	case -3:
+		if s.e == High && state == Low {
+			s.fall()
+		}
+		s.e = state
+	default:
+		if s.input[p.line] {
+			s.errors = append(s.errors, fmt.Sprintf("write to input D%d", p.line))
+		}
+		s.data[p.line] = state
+	}
+}
+
+func (p *lcdSimPin) Read() State {
+	s := p.sim
+	if p.line != 7 || s.rw != High || s.e != High || !s.input[7] {
+		s.errors = append(s.errors, "unexpected read")
+		return Low
+	}
+	if s.readPhase == 0 && s.now < s.busyUntil {
+		s.busyReads++
+		return High
+	}
+	return Low
+}
+
+// fall handles falling edge of E
+func (s *lcdSim) fall() {
+	if s.rw == High {
+		if !s.eightBit {
+			s.readPhase ^= 1
+		}
+		return
+	}
+	if s.now < s.busyUntil {
+		s.errors = append(s.errors, fmt.Sprintf("write while busy at %v", s.now))
+	}
+
+	var value int
+	for i, level := range s.data {
+		value |= int(level) << uint(i)
+	}
+	if !s.eightBit {
+		if s.pending < 0 {
+			s.pending = value & 0xf0
+			return
+		}
+		value, s.pending = s.pending|value>>4, -1
+	}
+	s.execute(byte(value))
+}
+
+func (s *lcdSim) execute(v byte) {
+	if s.reset && s.now < 40*time.Millisecond {
+		s.errors = append(s.errors, "instruction before power on")
+	}
+	busy := 37 * time.Microsecond
+	switch {
+	case s.rs == High:
+		if s.cgram {
+			s.cgramData[s.addr&0x3f] = v
+		} else {
+			s.ddram[s.addr&0x7f] = v
+		}
+		s.addr++
+	case v&0x80 != 0:
+		s.addr, s.cgram = int(v&0x7f), false
+	case v&0x40 != 0:
+		s.addr, s.cgram = int(v&0x3f), true
+	case v&0x20 != 0:
+		if s.reset {
+			busy = 4100 * time.Microsecond
+			s.reset = false
+		}
+		s.eightBit = v&0x10 != 0
+		s.function = v
+	case v&0x08 != 0:
+		s.display = v
+	case v&0x04 != 0:
+	case v&0x02 != 0:
+		s.addr = 0
+		busy = 1520 * time.Microsecond
+	case v == hd44780Clear:
+		for i := range s.ddram {
+			s.ddram[i] = ' '
+		}
+		s.addr = 0
+		busy = 1520 * time.Microsecond
+	}
+	s.busyUntil = s.now + busy
+}
+
+func newLcdSimDisplay(t *testing.T, sim *lcdSim, dataBits int, rw bool, cols, rows int) *HD44780 {
+	config := HD44780Config{
+		RS:      &lcdSimPin{sim, -1},
+		E:       &lcdSimPin{sim, -3},
+		Columns: cols,
+		Rows:    rows,
+	}
+	if rw {
+		config.RW = &lcdSimPin{sim, -2}
+	}
+	for i := 8 - dataBits; i < 8; i++ {
+		config.Data = append(config.Data, &lcdSimPin{sim, i})
+	}
+	d, err := NewHD44780(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.delay = sim.delay
+	return d
+}
+
+func TestHD44780FourBit(t *testing.T) {
+	sim := newLcdSim()
+	d := newLcdSimDisplay(t, sim, 4, true, 0, 0)
+	d.Init()
+	fmt.Fprint(d, "Hello\nWorld")
+
+	if sim.eightBit || sim.function != 0x28 || sim.display != 0x0c {
+		t.Errorf("function %#x, display %#x, 8-bit %v", sim.function, sim.display, sim.eightBit)
+	}
+	if got := string(sim.ddram[:5]); got != "Hello" {
+		t.Errorf("row 0 %q", got)
+	}
+	if got := string(sim.ddram[0x40:0x45]); got != "World" {
+		t.Errorf("row 1 %q", got)
+	}
+	if sim.busyReads == 0 {
+		t.Errorf("busy flag not polled")
+	}
+	for _, e := range sim.errors {
+		t.Error(e)
+	}
+}
+
+func TestHD44780EightBit(t *testing.T) {
+	sim := newLcdSim()
+	d := newLcdSimDisplay(t, sim, 8, false, 20, 4)
+	d.Init()
+
+	heart := [8]byte{0x00, 0x0a, 0x1f, 0x1f, 0x0e, 0x04, 0x00, 0x00}
+	d.SetCursor(18, 0)
+	d.CreateChar(1, heart)
+	d.Write([]byte{1, 'a', 'b'}) // wraps to row 1
+	d.SetCursor(2, 3)
+	d.Write([]byte("x\ny"))
+	d.Cursor(true)
+	d.Clear()
+	d.Write([]byte("z"))
+
+	if !sim.eightBit || sim.function != 0x38 || sim.display != 0x0e {
+		t.Errorf("function %#x, display %#x, 8-bit %v", sim.function, sim.display, sim.eightBit)
+	}
+	if !bytes.Equal(sim.cgramData[8:16], heart[:]) {
+		t.Errorf("cgram % x", sim.cgramData[8:16])
+	}
+	for i, c := range sim.ddram {
+		want := byte(' ')
+		if i == 0 {
+			want = 'z'
+		}
+		if c != want {
+			t.Errorf("ddram[%#x] = %q after clear", i, c)
+			break
+		}
+	}
+	for _, e := range sim.errors {
+		t.Error(e)
+	}
+}
+
+func TestHD44780Layout(t *testing.T) {
+	sim := newLcdSim()
+	d := newLcdSimDisplay(t, sim, 4, false, 20, 4)
+	d.Init()
+	d.SetCursor(18, 0)
+	d.Write([]byte("ab12"))
+	d.SetCursor(19, 3)
+	d.Write([]byte("cd\ne"))
+
+	// 'd' wraps from row 3 to row 0, then '\n' moves to row 1 and 'e' overwrites '1'
+	want := map[int]byte{18: 'a', 19: 'b', 0x40: 'e', 0x41: '2', 0x54 + 19: 'c', 0: 'd'}
+	for addr, c := range want {
+		if sim.ddram[addr] != c {
+			t.Errorf("ddram[%#x] = %q, want %q", addr, sim.ddram[addr], c)
+		}
+	}
+	for _, e := range sim.errors {
+		t.Error(e)
+	}
+
+	if _, err := NewHD44780(HD44780Config{Data: make([]DigitalPin, 5)}); err != HD44780ConfigError {
+		t.Errorf("error %v, want %v", err, HD44780ConfigError)
+	}
+}
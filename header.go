@@ -0,0 +1,140 @@
+package rpio
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Physical header pin number -> BCM pin, for each header revision.
+// Pins missing from the map are power or ground.
+var physicalPins = map[Header]map[int]Pin{
+	Header26Rev1: {
+		3: 0, 5: 1, 7: 4, 8: 14, 10: 15, 11: 17, 12: 18, 13: 21, 15: 22, 16: 23,
+		18: 24, 19: 10, 21: 9, 22: 25, 23: 11, 24: 8, 26: 7,
+	},
+	Header26Rev2: {
+		3: 2, 5: 3, 7: 4, 8: 14, 10: 15, 11: 17, 12: 18, 13: 27, 15: 22, 16: 23,
+		18: 24, 19: 10, 21: 9, 22: 25, 23: 11, 24: 8, 26: 7,
+	},
+	Header40: {
+		3: 2, 5: 3, 7: 4, 8: 14, 10: 15, 11: 17, 12: 18, 13: 27, 15: 22, 16: 23,
+		18: 24, 19: 10, 21: 9, 22: 25, 23: 11, 24: 8, 26: 7, 27: 0, 28: 1, 29: 5,
+		31: 6, 32: 12, 33: 13, 35: 19, 36: 16, 37: 26, 38: 20, 40: 21,
+	},
+}
+
+// WiringPi pin number -> BCM pin.
+// Pins 17-20 are on the P5 header, which only exists on rev 2 boards.
+var wiringPiPins = map[Header][]Pin{
+	Header26Rev1: {17, 18, 21, 22, 23, 24, 25, 4, 0, 1, 8, 7, 10, 9, 11, 14, 15},
+	Header26Rev2: {17, 18, 27, 22, 23, 24, 25, 4, 2, 3, 8, 7, 10, 9, 11, 14, 15, 28, 29, 30, 31},
+	Header40: {17, 18, 27, 22, 23, 24, 25, 4, 2, 3, 8, 7, 10, 9, 11, 14, 15, 255, 255, 255, 255,
+		5, 6, 13, 19, 26, 12, 16, 20, 21, 0, 1},
+}
+
+// PhysicalPin returns the BCM pin exposed on given physical header pin
+// of the detected board (eg. PhysicalPin(11) returns Pin(17)).
+func PhysicalPin(n int) (Pin, error) {
+	return physicalPin(boardHeader(), n)
+}
+
+// WiringPiPin returns the BCM pin with given wiringPi number
+// on the detected board (eg. WiringPiPin(0) returns Pin(17)).
+func WiringPiPin(n int) (Pin, error) {
+	return wiringPiPin(boardHeader(), n)
+}
+
+// ParsePin parses pin given in any of the following forms:
+//   "17", "GPIO17", "BCM17" - BCM number
+//   "pin 11", "P11", "phys11" - physical header pin
+//   "wpi0" - wiringPi number
+// Useful for config files, where pins can be given in the form used for wiring.
+func ParsePin(s string) (Pin, error) {
+	return parsePin(boardHeader(), s)
+}
+
+// Physical returns the physical header pin where the pin is exposed on the detected board.
+// Returns false if the pin is not available on the header.
+func (pin Pin) Physical() (int, bool) {
+	return pin.physical(boardHeader())
+}
+
+// String renders pin as "GPIO17 (pin 11)", or just "GPIO45" for pins not on the header
+func (pin Pin) String() string {
+	if n, ok := pin.Physical(); ok {
+		return fmt.Sprintf("GPIO%d (pin %d)", uint8(pin), n)
+	}
+	return fmt.Sprintf("GPIO%d", uint8(pin))
+}
+
+// boardHeader returns header of the detected board, defaults to the 40 pin header
+func boardHeader() Header {
+	board, err := Board()
+	if err != nil {
+		return Header40
+	}
+	return board.Header
+}
+
+func physicalPin(header Header, n int) (Pin, error) {
+	pins, ok := physicalPins[header]
+	if !ok {
+		return 0, fmt.Errorf("rpio: board has no %s header", header)
+	}
+	pin, ok := pins[n]
+	if !ok {
+		return 0, fmt.Errorf("rpio: physical pin %d is not a GPIO on %s header", n, header)
+	}
+	return pin, nil
+}
+
+func wiringPiPin(header Header, n int) (Pin, error) {
+	pins, ok := wiringPiPins[header]
+	if !ok {
+		return 0, fmt.Errorf("rpio: board has no %s header", header)
+	}
+	if n < 0 || n >= len(pins) || pins[n] == 255 {
+		return 0, fmt.Errorf("rpio: wiringPi pin %d is not available on %s header", n, header)
+	}
+	return pins[n], nil
+}
+
+func (pin Pin) physical(header Header) (int, bool) {
+	for n, p := range physicalPins[header] {
+		if p == pin {
+			return n, true
+		}
+	}
+	return 0, false
+}
+
+func parsePin(header Header, s string) (Pin, error) {
+	str := strings.ToLower(strings.Join(strings.Fields(s), ""))
+
+	number := func(prefix string) (int, bool) {
+		if !strings.HasPrefix(str, prefix) {
+			return 0, false
+		}
+		n, err := strconv.Atoi(str[len(prefix):])
+		return n, err == nil
+	}
+
+	for _, prefix := range []string{"gpio", "bcm", ""} {
+		if n, ok := number(prefix); ok {
+			if n < 0 || n > 255 {
+				return 0, fmt.Errorf("rpio: invalid pin %q", s)
+			}
+			return Pin(n), nil
+		}
+	}
+	for _, prefix := range []string{"pin", "phys", "p"} {
+		if n, ok := number(prefix); ok {
+			return physicalPin(header, n)
+		}
+	}
+	if n, ok := number("wpi"); ok {
+		return wiringPiPin(header, n)
+	}
+	return 0, fmt.Errorf("rpio: invalid pin %q", s)
+}
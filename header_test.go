@@ -0,0 +1,110 @@
+package rpio
+
+import "testing"
+
+func TestPhysicalPin(t *testing.T) {
+	tests := []struct {
+		header   Header
+		physical int
+		pin      Pin
+	}{
+		{Header40, 3, 2},
+		{Header40, 11, 17},
+		{Header40, 13, 27},
+		{Header40, 40, 21},
+		{Header26Rev2, 3, 2},
+		{Header26Rev1, 3, 0},
+		{Header26Rev1, 13, 21},
+	}
+	for _, test := range tests {
+		pin, err := physicalPin(test.header, test.physical)
+		if err != nil {
+			t.Errorf("%s pin %d: %v", test.header, test.physical, err)
+			continue
+		}
+		if pin != test.pin {
+			t.Errorf("%s pin %d: got GPIO%d, want GPIO%d", test.header, test.physical, pin, test.pin)
+		}
+		if n, ok := pin.physical(test.header); !ok || n != test.physical {
+			t.Errorf("%s GPIO%d: reverse lookup got %d, %v", test.header, pin, n, ok)
+		}
+	}
+
+	for _, physical := range []int{0, 1, 6, 39, 41} {
+		if _, err := physicalPin(Header40, physical); err == nil {
+			t.Errorf("pin %d: expected error", physical)
+		}
+	}
+	if _, err := physicalPin(Header26Rev2, 27); err == nil {
+		t.Error("pin 27 should not exist on 26 pin header")
+	}
+	if _, err := physicalPin(NoHeader, 3); err == nil {
+		t.Error("compute module should have no header")
+	}
+	if _, ok := Pin(45).physical(Header40); ok {
+		t.Error("GPIO45 should not be on header")
+	}
+}
+
+func TestWiringPiPin(t *testing.T) {
+	tests := []struct {
+		header Header
+		wpi    int
+		pin    Pin
+	}{
+		{Header40, 0, 17},
+		{Header40, 2, 27},
+		{Header40, 8, 2},
+		{Header40, 21, 5},
+		{Header40, 31, 1},
+		{Header26Rev2, 17, 28},
+		{Header26Rev1, 2, 21},
+		{Header26Rev1, 8, 0},
+	}
+	for _, test := range tests {
+		pin, err := wiringPiPin(test.header, test.wpi)
+		if err != nil {
+			t.Errorf("%s wpi %d: %v", test.header, test.wpi, err)
+			continue
+		}
+		if pin != test.pin {
+			t.Errorf("%s wpi %d: got GPIO%d, want GPIO%d", test.header, test.wpi, pin, test.pin)
+		}
+	}
+
+	for _, wpi := range []int{-1, 17, 20, 32} {
+		if _, err := wiringPiPin(Header40, wpi); err == nil {
+			t.Errorf("wpi %d: expected error", wpi)
+		}
+	}
+}
+
+func TestParsePin(t *testing.T) {
+	tests := map[string]Pin{
+		"17":     17,
+		"GPIO17": 17,
+		"gpio 4": 4,
+		"BCM27":  27,
+		"pin 11": 17,
+		"P11":    17,
+		"phys40": 21,
+		"wpi0":   17,
+		"WPi 8":  2,
+	}
+	for s, want := range tests {
+		pin, err := parsePin(Header40, s)
+		if err != nil {
+			t.Errorf("%q: %v", s, err)
+			continue
+		}
+		if pin != want {
+			t.Errorf("%q: got GPIO%d, want GPIO%d", s, pin, want)
+		}
+	}
+
+	for _, s := range []string{"", "GPIO", "pin 1", "wpi17", "256", "foo"} {
+		if _, err := parsePin(Header40, s); err == nil {
+			t.Errorf("%q: expected error", s)
+		}
+	}
+}
@@ -0,0 +1,579 @@
+package rpio
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// IRProtocol is an infrared remote control protocol
+type IRProtocol uint8
+
+const (
+	IRNEC  IRProtocol = iota // NEC and extended NEC, 38kHz
+	IRRC5                    // Philips RC5 and RC5X, 36kHz
+	IRRC6                    // Philips RC6 mode 0, 36kHz
+	IRSony                   // Sony SIRC 12, 15 and 20 bit, 40kHz
+)
+
+func (p IRProtocol) String() string {
+	switch p {
+	case IRNEC:
+		return "NEC"
+	case IRRC5:
+		return "RC5"
+	case IRRC6:
+		return "RC6"
+	case IRSony:
+		return "Sony"
+	}
+	return "unknown"
+}
+
+// Carrier returns carrier frequency [Hz] of the protocol
+func (p IRProtocol) Carrier() int {
+	switch p {
+	case IRRC5, IRRC6:
+		return 36000
+	case IRSony:
+		return 40000
+	}
+	return 38000
+}
+
+var (
+	IRDecodeError = errors.New("unknown IR protocol or damaged frame")
+	IRFrameError  = errors.New("IR frame fields out of range of the protocol")
+	IRPinError    = errors.New("pin has no clock or PWM output")
+)
+
+// IRFrame is a command of a remote control
+type IRFrame struct {
+	Protocol IRProtocol
+	Address  uint16        // 8 bits (16 bits extended NEC), RC5 5 bits, RC6 8 bits, Sony 5, 8 or 13 bits
+	Command  uint16        // 8 bits, RC5 7 bits (6 bits in RC5 without extension), Sony 7 bits
+	Bits     int           // Sony frame length 12, 15 or 20, zero means 12
+	Toggle   bool          // RC5/RC6 toggle bit, changes with each key press
+	Repeat   bool          // NEC repeat code, or same frame received again while the key is held
+	Time     time.Duration // start of the frame
+}
+
+// Timing of protocols [µs]
+const (
+	necUnit  = 562
+	sonyUnit = 600
+	rc5Unit  = 889 // half of bit
+	rc6Unit  = 444 // half of bit
+
+	irFrameGap    = 5 * time.Millisecond // longest space within a frame is NEC header (4.5ms)
+	irRepeatDelay = 200 * time.Millisecond
+)
+
+func micros(n int) time.Duration {
+	return time.Duration(n) * time.Microsecond
+}
+
+// EncodeIR: Encodes frame to lengths of alternating marks (carrier on) and spaces, starting and ending with a mark.
+// Repeat of NEC frame is encoded as the repeat code.
+func EncodeIR(frame IRFrame) ([]time.Duration, error) {
+	switch frame.Protocol {
+	case IRNEC:
+		if frame.Repeat {
+			return []time.Duration{micros(16 * necUnit), micros(4 * necUnit), micros(necUnit)}, nil
+		}
+		if frame.Command > 0xff {
+			return nil, IRFrameError
+		}
+		address := uint32(frame.Address)
+		if frame.Address <= 0xff {
+			address |= uint32(^uint8(frame.Address)) << 8
+		}
+		value := address | uint32(frame.Command)<<16 | uint32(^uint8(frame.Command))<<24
+
+		pulses := []time.Duration{micros(16 * necUnit), micros(8 * necUnit)}
+		for i := uint(0); i < 32; i++ {
+			space := micros(necUnit)
+			if value>>i&1 != 0 {
+				space = micros(3 * necUnit)
+			}
+			pulses = append(pulses, micros(necUnit), space)
+		}
+		return append(pulses, micros(necUnit)), nil
+
+	case IRSony:
+		bits := frame.Bits
+		if bits == 0 {
+			bits = 12
+		}
+		if (bits != 12 && bits != 15 && bits != 20) || frame.Command > 0x7f || frame.Address >= 1<<uint(bits-7) {
+			return nil, IRFrameError
+		}
+		value := uint32(frame.Command) | uint32(frame.Address)<<7
+		pulses := []time.Duration{micros(4 * sonyUnit)}
+		for i := uint(0); i < uint(bits); i++ {
+			mark := micros(sonyUnit)
+			if value>>i&1 != 0 {
+				mark = micros(2 * sonyUnit)
+			}
+			pulses = append(pulses, micros(sonyUnit), mark)
+		}
+		return pulses, nil
+
+	case IRRC5:
+		if frame.Address > 0x1f || frame.Command > 0x7f {
+			return nil, IRFrameError
+		}
+		// start, field (inverted command bit 6), toggle, 5 bits address, 6 bits command, 1 is space-mark
+		value := 1<<13 | uint32(^frame.Command>>6&1)<<12 | uint32(frame.Address)<<6 | uint32(frame.Command&0x3f)
+		if frame.Toggle {
+			value |= 1 << 11
+		}
+		var levels []bool
+		for i := 13; i >= 0; i-- {
+			bit := value>>uint(i)&1 != 0
+			levels = append(levels, !bit, bit)
+		}
+		return irPulses(levels, micros(rc5Unit)), nil
+
+	case IRRC6:
+		if frame.Address > 0xff || frame.Command > 0xff {
+			return nil, IRFrameError
+		}
+		levels := []bool{true, true, true, true, true, true, false, false} // leader
+		levels = append(levels, true, false)                               // start bit
+		levels = append(levels, false, true, false, true, false, true)     // mode 0
+		if frame.Toggle {
+			levels = append(levels, true, true, false, false)
+		} else {
+			levels = append(levels, false, false, true, true)
+		}
+		value := uint32(frame.Address)<<8 | uint32(frame.Command)
+		for i := 15; i >= 0; i-- { // 1 is mark-space
+			bit := value>>uint(i)&1 != 0
+			levels = append(levels, bit, !bit)
+		}
+		return irPulses(levels, micros(rc6Unit)), nil
+	}
+	return nil, IRFrameError
+}
+
+// irPulses converts levels of time units (true is mark) to lengths of marks and spaces,
+// leading and trailing spaces are dropped
+func irPulses(levels []bool, unit time.Duration) []time.Duration {
+	for len(levels) > 0 && !levels[0] {
+		levels = levels[1:]
+	}
+	for len(levels) > 0 && !levels[len(levels)-1] {
+		levels = levels[:len(levels)-1]
+	}
+	var pulses []time.Duration
+	for i, level := range levels {
+		if i > 0 && level == levels[i-1] {
+			pulses[len(pulses)-1] += unit
+		} else {
+			pulses = append(pulses, unit)
+		}
+	}
+	return pulses
+}
+
+// irLevels converts lengths of marks and spaces (starting with a mark) to levels of time units,
+// each pulse has to be 1 - max units long
+func irLevels(pulses []time.Duration, unit time.Duration, max int) ([]bool, bool) {
+	var levels []bool
+	for i, pulse := range pulses {
+		n := int((pulse + unit/2) / unit)
+		if n < 1 || n > max {
+			return nil, false
+		}
+		for ; n > 0; n-- {
+			levels = append(levels, i%2 == 0)
+		}
+	}
+	return levels, true
+}
+
+// irMatch checks whether measured pulse matches nominal length, receivers distort pulses by up to ~100µs
+func irMatch(pulse time.Duration, nominal int) bool {
+	diff := pulse - micros(nominal)
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff <= micros(nominal/5+100)
+}
+
+// DecodeIR decodes frame from lengths of alternating marks and spaces, starting with a mark.
+// NEC repeat code is returned as a frame with only Repeat set.
+func DecodeIR(pulses []time.Duration) (IRFrame, error) {
+	// Manchester coded frames are checked before Sony, their structure is stricter
+	for _, decode := range []func([]time.Duration) (IRFrame, bool){decodeNEC, decodeRC5, decodeRC6, decodeSony} {
+		if frame, ok := decode(pulses); ok {
+			return frame, nil
+		}
+	}
+	return IRFrame{}, IRDecodeError
+}
+
+func decodeNEC(pulses []time.Duration) (IRFrame, bool) {
+	if len(pulses) < 3 || !irMatch(pulses[0], 16*necUnit) {
+		return IRFrame{}, false
+	}
+	if len(pulses) == 3 && irMatch(pulses[1], 4*necUnit) && irMatch(pulses[2], necUnit) {
+		return IRFrame{Protocol: IRNEC, Repeat: true}, true
+	}
+	if len(pulses) != 2+64+1 || !irMatch(pulses[1], 8*necUnit) {
+		return IRFrame{}, false
+	}
+
+	var value uint32
+	for i := uint(0); i < 32; i++ {
+		mark, space := pulses[2+2*i], pulses[3+2*i]
+		if !irMatch(mark, necUnit) {
+			return IRFrame{}, false
+		}
+		if space > micros(2*necUnit) {
+			value |= 1 << i
+		}
+	}
+	command := uint8(value >> 16)
+	if uint8(value>>24) != ^command {
+		return IRFrame{}, false
+	}
+	frame := IRFrame{Protocol: IRNEC, Address: uint16(value), Command: uint16(command)}
+	if uint8(value>>8) == ^uint8(value) {
+		frame.Address &= 0xff
+	}
+	return frame, true
+}
+
+func decodeSony(pulses []time.Duration) (IRFrame, bool) {
+	bits := (len(pulses) - 1) / 2
+	if (bits != 12 && bits != 15 && bits != 20) || len(pulses)%2 == 0 || !irMatch(pulses[0], 4*sonyUnit) {
+		return IRFrame{}, false
+	}
+
+	var value uint32
+	for i := uint(0); i < uint(bits); i++ {
+		space, mark := pulses[1+2*i], pulses[2+2*i]
+		if !irMatch(space, sonyUnit) {
+			return IRFrame{}, false
+		}
+		if mark > micros(3*sonyUnit/2) {
+			value |= 1 << i
+		}
+	}
+	return IRFrame{Protocol: IRSony, Address: uint16(value >> 7), Command: uint16(value & 0x7f), Bits: bits}, true
+}
+
+func decodeRC5(pulses []time.Duration) (IRFrame, bool) {
+	levels, ok := irLevels(pulses, micros(rc5Unit), 2)
+	if !ok {
+		return IRFrame{}, false
+	}
+	levels = append([]bool{false}, levels...) // first half of start bit
+	if len(levels) > 28 {
+		return IRFrame{}, false
+	}
+	for len(levels) < 28 { // second half of last bit
+		levels = append(levels, false)
+	}
+
+	var value uint32
+	for i := 0; i < 28; i += 2 {
+		if levels[i] == levels[i+1] {
+			return IRFrame{}, false
+		}
+		value <<= 1
+		if levels[i+1] {
+			value |= 1
+		}
+	}
+	if value>>13 != 1 {
+		return IRFrame{}, false
+	}
+	return IRFrame{
+		Protocol: IRRC5,
+		Address:  uint16(value >> 6 & 0x1f),
+		Command:  uint16(value&0x3f) | uint16(^value>>12&1)<<6,
+		Toggle:   value>>11&1 != 0,
+	}, true
+}
+
+func decodeRC6(pulses []time.Duration) (IRFrame, bool) {
+	if len(pulses) < 3 || !irMatch(pulses[0], 6*rc6Unit) || !irMatch(pulses[1], 2*rc6Unit) {
+		return IRFrame{}, false
+	}
+	levels, ok := irLevels(pulses[2:], micros(rc6Unit), 3)
+	if !ok || len(levels) > 44 {
+		return IRFrame{}, false
+	}
+	for len(levels) < 44 { // second half of last bit
+		levels = append(levels, false)
+	}
+
+	// start bit and mode 0
+	for i, level := range []bool{true, false, false, true, false, true, false, true} {
+		if levels[i] != level {
+			return IRFrame{}, false
+		}
+	}
+	var toggle bool
+	switch {
+	case levels[8] && levels[9] && !levels[10] && !levels[11]:
+		toggle = true
+	case !levels[8] && !levels[9] && levels[10] && levels[11]:
+	default:
+		return IRFrame{}, false
+	}
+
+	var value uint16
+	for i := 12; i < 44; i += 2 {
+		if levels[i] == levels[i+1] {
+			return IRFrame{}, false
+		}
+		value <<= 1
+		if levels[i] {
+			value |= 1
+		}
+	}
+	return IRFrame{Protocol: IRRC6, Address: value >> 8, Command: value & 0xff, Toggle: toggle}, true
+}
+
+// IRDecoder decodes frames from edges of IR receiver output (TSOP and similar, Low while carrier is received).
+// The edges can come from any source, see IRReceiver.
+type IRDecoder struct {
+	events chan IRFrame
+
+	mu       sync.Mutex
+	pulses   []time.Duration
+	inFrame  bool
+	level    State
+	last     time.Duration // time of last edge
+	start    time.Duration // of frame
+	previous IRFrame
+	errors   int
+}
+
+// NewIRDecoder creates decoder, the receiver output is expected High (idle)
+func NewIRDecoder() *IRDecoder {
+	return &IRDecoder{events: make(chan IRFrame, 64), level: High}
+}
+
+// Events returns channel of decoded frames, frames are dropped when it is full
+func (d *IRDecoder) Events() <-chan IRFrame {
+	return d.events
+}
+
+// Errors returns number of frames that could not be decoded
+func (d *IRDecoder) Errors() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.errors
+}
+
+// Add processes level change, events have to be added in order
+func (d *IRDecoder) Add(event EdgeEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if event.Level == d.level {
+		return
+	}
+	length := event.Time - d.last
+	d.level, d.last = event.Level, event.Time
+
+	if event.Level == High { // mark ends
+		if d.inFrame {
+			d.pulses = append(d.pulses, length)
+		}
+		return
+	}
+	// mark starts, after space of given length
+	if d.inFrame && length < irFrameGap {
+		d.pulses = append(d.pulses, length)
+		return
+	}
+	if d.inFrame {
+		d.end()
+	}
+	d.inFrame, d.start = true, event.Time
+}
+
+// Update decodes frame that ended before now (the receiver is idle long enough).
+// It has to be called periodically, with time of the same source as the edges.
+func (d *IRDecoder) Update(now time.Duration) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if d.inFrame && d.level == High && now-d.last >= irFrameGap {
+		d.end()
+	}
+}
+
+// end decodes collected pulses and emits the frame
+func (d *IRDecoder) end() {
+	frame, err := DecodeIR(d.pulses)
+	d.pulses, d.inFrame = d.pulses[:0], false
+	if err != nil {
+		d.errors++
+		return
+	}
+	recent := d.previous.Time != 0 && d.start-d.previous.Time <= irRepeatDelay
+	if frame.Repeat {
+		if !recent || d.previous.Protocol != IRNEC {
+			d.errors++
+			return
+		}
+		frame = d.previous
+		frame.Repeat = true
+	} else {
+		frame.Repeat = recent && frame.Protocol == d.previous.Protocol && frame.Address == d.previous.Address &&
+			frame.Command == d.previous.Command && frame.Bits == d.previous.Bits && frame.Toggle == d.previous.Toggle
+	}
+	frame.Time = d.start
+	d.previous = frame
+
+	select {
+	case d.events <- frame:
+	default:
+	}
+}
+
+// IRReceiver decodes frames received on input pin, see IRDecoder.
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available,
+// otherwise the pin is polled in a busy loop (see WatchEdges), which takes one CPU core.
+type IRReceiver struct {
+	*IRDecoder
+
+	file *os.File
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewIRReceiver starts receiving on input pin, call Close when done
+func NewIRReceiver(pin Pin) (*IRReceiver, error) {
+	r := &IRReceiver{IRDecoder: NewIRDecoder(), stop: make(chan struct{}), done: make(chan struct{})}
+
+	events, file, err := watchLineEdges(pin, "rpio-ir", r.stop)
+	if err != nil {
+		return nil, err
+	}
+	r.file = file
+
+	go func() {
+		defer close(r.done)
+		ticker := time.NewTicker(irFrameGap)
+		defer ticker.Stop()
+		for {
+			select {
+			case event, ok := <-events:
+				if !ok {
+					return
+				}
+				r.Add(event)
+			case <-ticker.C:
+				r.Update(systemTime())
+			}
+		}
+	}()
+	return r, nil
+}
+
+// Close stops receiving
+func (r *IRReceiver) Close() error {
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+		r.stop = nil
+	}
+	return err
+}
+
+// IRTransmitter sends frames by IR LED (driven by a transistor) on a pin with clock (GPCLK) or PWM output.
+// The carrier runs continuously, marks are sent by switching the pin between the carrier and Low output.
+//
+// Note that the clocks are shared by groups of pins (see SetFreq), PWM clock is also used by Servo.
+type IRTransmitter struct {
+	pin     Pin
+	mode    Mode // Clock or Pwm
+	carrier int
+
+	gate  func(mark bool) // replaces pin in tests
+	now   func() time.Duration
+	delay func(us uint32)
+	mu    sync.Mutex
+}
+
+// NewIRTransmitter creates transmitter on a pin with clock output (4, 5, 6, 20, 21) or PWM (12, 13, 18, 19)
+func NewIRTransmitter(pin Pin) (*IRTransmitter, error) {
+	t := &IRTransmitter{pin: pin, now: systemTime, delay: DelayMicroseconds}
+	switch pin {
+	case 4, 5, 6, 20, 21, 32, 34, 42, 43, 44:
+		t.mode = Clock
+	case 12, 13, 18, 19, 40, 41, 45:
+		t.mode = Pwm
+	default:
+		return nil, IRPinError
+	}
+	pin.Write(Low)
+	pin.Output()
+	return t, nil
+}
+
+// Send encodes and transmits frame with carrier of its protocol
+func (t *IRTransmitter) Send(frame IRFrame) error {
+	pulses, err := EncodeIR(frame)
+	if err != nil {
+		return err
+	}
+	t.SendPulses(pulses, frame.Protocol.Carrier())
+	return nil
+}
+
+// SendPulses transmits lengths of alternating marks and spaces (starting with a mark) with given carrier [Hz]
+func (t *IRTransmitter) SendPulses(pulses []time.Duration, carrier int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.gate == nil && carrier != t.carrier {
+		if t.mode == Clock {
+			SetFreq(t.pin, carrier)
+		} else {
+			SetDutyCycle(t.pin, 1, 3) // duty cycle 1/3 saves the LED
+			SetFreq(t.pin, carrier*3)
+		}
+		t.carrier = carrier
+	}
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	next := t.now()
+	for i, pulse := range pulses {
+		t.output(i%2 == 0)
+		next += pulse // scheduled from start, so delays do not accumulate
+		if wait := next - t.now(); wait > 0 {
+			t.delay(uint32(wait / time.Microsecond))
+		}
+	}
+	t.output(false)
+}
+
+func (t *IRTransmitter) output(mark bool) {
+	if t.gate != nil {
+		t.gate(mark)
+		return
+	}
+	if mark {
+		PinMode(t.pin, t.mode)
+	} else {
+		PinMode(t.pin, Output) // Low
+	}
+}
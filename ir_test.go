@@ -0,0 +1,211 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+// irRecordings are pulses [µs] captured from receiver output, marks are longer and spaces shorter than nominal
+var irRecordings = []struct {
+	name   string
+	pulses []int
+	frame  IRFrame
+}{
+	{"NEC", []int{
+		9065, 4414, 632, 508, 610, 471, 641, 473, 630, 504, 610, 499, 620, 469, 612, 494, 633, 471, 622, 1597, 642, 1619,
+		610, 1628, 614, 1606, 647, 1632, 644, 1595, 643, 1629, 632, 1595, 621, 1594, 642, 475, 625, 1618, 616, 501, 614,
+		503, 626, 502, 650, 1603, 613, 504, 643, 507, 619, 1615, 613, 502, 652, 1596, 643, 1595, 646, 1605, 638, 510,
+		641, 1619, 656,
+	}, IRFrame{Protocol: IRNEC, Address: 0x00, Command: 0x45}},
+	{"NEC repeat", []int{9065, 2184, 644}, IRFrame{Protocol: IRNEC, Repeat: true}},
+	{"RC5", []int{
+		963, 817, 953, 809, 1873, 805, 978, 843, 949, 799, 970, 813, 967, 825, 955, 840, 962, 1701, 972, 798, 1830, 826, 960,
+	}, IRFrame{Protocol: IRRC5, Address: 0, Command: 12, Toggle: true}},
+	{"RC6", []int{
+		2719, 841, 510, 802, 520, 375, 491, 391, 1381, 1285, 524, 385, 539, 369, 510, 393, 511, 387, 964, 830, 518, 353,
+		494, 366, 519, 393, 531, 353, 492, 395, 977, 368, 530, 829, 532, 377, 507,
+	}, IRFrame{Protocol: IRRC6, Address: 4, Command: 0x0c, Toggle: true}},
+	{"Sony", []int{
+		2490, 529, 1287, 527, 646, 534, 1267, 515, 684, 512, 1276, 508, 658, 554, 663, 513, 1292, 520, 670, 530, 676,
+		510, 655, 533, 670,
+	}, IRFrame{Protocol: IRSony, Address: 1, Command: 21, Bits: 12}},
+}
+
+func irDurations(pulses []int) []time.Duration {
+	d := make([]time.Duration, len(pulses))
+	for i, p := range pulses {
+		d[i] = time.Duration(p) * time.Microsecond
+	}
+	return d
+}
+
+func TestDecodeIR(t *testing.T) {
+	for _, rec := range irRecordings {
+		frame, err := DecodeIR(irDurations(rec.pulses))
+		if err != nil || frame != rec.frame {
+			t.Errorf("%s: %+v %v, want %+v", rec.name, frame, err, rec.frame)
+		}
+	}
+
+	damaged := irDurations(irRecordings[0].pulses)
+	damaged[40] = 1100 * time.Microsecond // mark of a bit too long
+	if _, err := DecodeIR(damaged); err != IRDecodeError {
+		t.Errorf("damaged frame: error %v", err)
+	}
+}
+
+func TestEncodeIR(t *testing.T) {
+	frames := []IRFrame{
+		{Protocol: IRNEC, Address: 0x04, Command: 0x08},
+		{Protocol: IRNEC, Address: 0x7f01, Command: 0xff}, // extended
+		{Protocol: IRNEC, Repeat: true},
+		{Protocol: IRRC5, Address: 0x1f, Command: 0x3f},
+		{Protocol: IRRC5, Address: 5, Command: 0x45, Toggle: true}, // RC5X
+		{Protocol: IRRC5, Address: 0, Command: 0},
+		{Protocol: IRRC6, Address: 0xff, Command: 0},
+		{Protocol: IRRC6, Address: 0, Command: 0xff, Toggle: true},
+		{Protocol: IRSony, Address: 0x1f, Command: 0x7f, Bits: 12},
+		{Protocol: IRSony, Address: 0x9a, Command: 0x15, Bits: 15},
+		{Protocol: IRSony, Address: 0x1abc, Command: 0x01, Bits: 20},
+	}
+	for _, frame := range frames {
+		pulses, err := EncodeIR(frame)
+		if err != nil {
+			t.Errorf("%+v: %v", frame, err)
+			continue
+		}
+		if len(pulses)%2 != 1 {
+			t.Errorf("%+v: does not end with mark", frame)
+		}
+		decoded, err := DecodeIR(pulses)
+		if err != nil || decoded != frame {
+			t.Errorf("%+v decoded as %+v %v", frame, decoded, err)
+		}
+	}
+
+	for _, frame := range []IRFrame{
+		{Protocol: IRNEC, Command: 0x100},
+		{Protocol: IRRC5, Address: 0x20},
+		{Protocol: IRSony, Address: 0x20, Bits: 12},
+		{Protocol: IRSony, Bits: 16},
+	} {
+		if _, err := EncodeIR(frame); err != IRFrameError {
+			t.Errorf("%+v: error %v, want %v", frame, err, IRFrameError)
+		}
+	}
+}
+
+// irEdges converts pulses starting at given time to edges of receiver output (Low during marks)
+func irEdges(d *IRDecoder, start time.Duration, pulses []time.Duration) time.Duration {
+	t := start
+	for i, pulse := range pulses {
+		level := Low
+		if i%2 != 0 {
+			level = High
+		}
+		d.Add(EdgeEvent{Level: level, Time: t})
+		t += pulse
+	}
+	d.Add(EdgeEvent{Level: High, Time: t})
+	return t
+}
+
+func TestIRDecoder(t *testing.T) {
+	d := NewIRDecoder()
+	ms := time.Millisecond
+	nec := irDurations(irRecordings[0].pulses)
+	repeat := irDurations(irRecordings[1].pulses)
+
+	end := irEdges(d, 10*ms, nec)
+	d.Update(end + 2*ms)
+	select {
+	case frame := <-d.Events():
+		t.Fatalf("frame %+v before end of gap", frame)
+	default:
+	}
+	irEdges(d, 10*ms+108*ms, repeat) // decoded at start of next frame
+	end = irEdges(d, 10*ms+216*ms, repeat)
+	d.Update(end + 10*ms)
+
+	want := []IRFrame{
+		{Protocol: IRNEC, Command: 0x45, Time: 10 * ms},
+		{Protocol: IRNEC, Command: 0x45, Repeat: true, Time: 118 * ms},
+		{Protocol: IRNEC, Command: 0x45, Repeat: true, Time: 226 * ms},
+	}
+	for _, w := range want {
+		select {
+		case frame := <-d.Events():
+			if frame != w {
+				t.Errorf("frame %+v, want %+v", frame, w)
+			}
+		default:
+			t.Fatalf("missing frame %+v", w)
+		}
+	}
+
+	// RC5 held key repeats same frame, next press toggles
+	rc5 := IRFrame{Protocol: IRRC5, Address: 3, Command: 7}
+	start := time.Second
+	for i, toggle := range []bool{false, false, true} {
+		rc5.Toggle = toggle
+		pulses, _ := EncodeIR(rc5)
+		end = irEdges(d, start, pulses)
+		d.Update(end + 10*ms)
+		frame := <-d.Events()
+		if frame.Toggle != toggle || frame.Repeat != (i == 1) || frame.Time != start {
+			t.Errorf("RC5 frame %d: %+v", i, frame)
+		}
+		start += 114 * ms
+	}
+
+	// repeat code without frame
+	irEdges(d, 5*time.Second, repeat)
+	d.Update(6 * time.Second)
+	if d.Errors() != 1 || len(d.Events()) != 0 {
+		t.Errorf("%d errors, %d frames", d.Errors(), len(d.Events()))
+	}
+}
+
+func TestIRTransmitter(t *testing.T) {
+	if _, err := NewIRTransmitter(17); err != IRPinError {
+		t.Errorf("pin 17: error %v, want %v", err, IRPinError)
+	}
+
+	var now time.Duration
+	var marks []bool
+	var times []time.Duration
+	tx := &IRTransmitter{
+		pin:   18,
+		mode:  Pwm,
+		gate:  func(mark bool) { marks = append(marks, mark); times = append(times, now) },
+		now:   func() time.Duration { now += 3 * time.Microsecond; return now }, // slow reads of timer
+		delay: func(us uint32) { now += time.Duration(us) * time.Microsecond },
+	}
+	frame := IRFrame{Protocol: IRSony, Address: 1, Command: 21}
+	if err := tx.Send(frame); err != nil {
+		t.Fatal(err)
+	}
+	pulses, _ := EncodeIR(frame)
+	if len(marks) != len(pulses)+1 {
+		t.Fatalf("%d gate changes for %d pulses", len(marks), len(pulses))
+	}
+	for i := range pulses {
+		if marks[i] != (i%2 == 0) {
+			t.Fatalf("change %d: mark %v", i, marks[i])
+		}
+		// scheduled from start, the error does not accumulate
+		if length := times[i+1] - times[i]; length < pulses[i]-10*time.Microsecond || length > pulses[i]+10*time.Microsecond {
+			t.Errorf("pulse %d: %v, want %v", i, length, pulses[i])
+		}
+	}
+	if total, want := times[len(times)-1]-times[0], sumDurations(pulses); total < want || total > want+10*time.Microsecond {
+		t.Errorf("frame took %v, want %v", total, want)
+	}
+}
+
+func sumDurations(d []time.Duration) (sum time.Duration) {
+	for _, x := range d {
+		sum += x
+	}
+	return sum
+}
@@ -0,0 +1,217 @@
+package rpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var KeypadConfigError = errors.New("keymap does not match keypad rows and columns")
+
+// Keymaps of common membrane keypads, by rows
+var (
+	Keymap4x4 = [][]rune{
+		{'1', '2', '3', 'A'},
+		{'4', '5', '6', 'B'},
+		{'7', '8', '9', 'C'},
+		{'*', '0', '#', 'D'},
+	}
+	Keymap4x3 = [][]rune{
+		{'1', '2', '3'},
+		{'4', '5', '6'},
+		{'7', '8', '9'},
+		{'*', '0', '#'},
+	}
+)
+
+// KeypadConfig: Settings of Keypad, zero values mean 4x4 keymap, scan every 10ms and debounce time 20ms
+type KeypadConfig struct {
+	Keymap       [][]rune // keys by rows and columns
+	ScanInterval time.Duration
+	DebounceTime time.Duration // key state has to be stable for this time to be accepted
+}
+
+// KeyEvent is a debounced change of a key
+type KeyEvent struct {
+	Key    rune
+	Row    int
+	Column int
+	Down   bool          // pressed, false when released
+	Time   time.Duration // system timer, see SystemTime
+}
+
+// Keypad scans matrix keypad. Rows are driven Low one at a time (the others are left floating as inputs),
+// columns are read with pull-ups, so a pressed key reads Low.
+//
+// Without diodes, 3 keys pressed in corners of a rectangle make the fourth one look pressed (ghosting).
+// Scans with such a rectangle are ignored, the keys keep their previous state until it is released.
+type Keypad struct {
+	rows    []DigitalPin
+	columns []DigitalPin
+	config  KeypadConfig
+	events  chan KeyEvent
+	delay   func(us uint32)
+
+	mu       sync.Mutex
+	state    [][]bool          // debounced
+	raw      [][]bool          // last scan
+	changed  [][]time.Duration // when raw state changed
+	ghosting bool
+}
+
+// NewKeypad: Sets row pins to Input, column pins to Input with pull-up (when they are Pins), see KeypadConfig.
+// Call Run to start scanning.
+func NewKeypad(rows, columns []DigitalPin, config KeypadConfig) (*Keypad, error) {
+	if config.Keymap == nil {
+		config.Keymap = Keymap4x4
+	}
+	if len(config.Keymap) != len(rows) {
+		return nil, KeypadConfigError
+	}
+	for _, keys := range config.Keymap {
+		if len(keys) != len(columns) {
+			return nil, KeypadConfigError
+		}
+	}
+	if config.ScanInterval == 0 {
+		config.ScanInterval = 10 * time.Millisecond
+	}
+	if config.DebounceTime == 0 {
+		config.DebounceTime = 20 * time.Millisecond
+	}
+
+	for _, row := range rows {
+		row.Write(Low) // driven when switched to Output
+		row.Input()
+	}
+	for _, column := range columns {
+		column.Input()
+		if pin, ok := column.(interface{ PullUp() }); ok {
+			pin.PullUp()
+		}
+	}
+
+	k := &Keypad{
+		rows:    rows,
+		columns: columns,
+		config:  config,
+		events:  make(chan KeyEvent, 64),
+		delay:   DelayMicroseconds,
+	}
+	k.state = k.matrix()
+	k.raw = k.matrix()
+	k.changed = make([][]time.Duration, len(rows))
+	for i := range k.changed {
+		k.changed[i] = make([]time.Duration, len(columns))
+	}
+	return k, nil
+}
+
+func (k *Keypad) matrix() [][]bool {
+	m := make([][]bool, len(k.rows))
+	for i := range m {
+		m[i] = make([]bool, len(k.columns))
+	}
+	return m
+}
+
+// Events returns channel of key changes, events are dropped when it is full
+func (k *Keypad) Events() <-chan KeyEvent {
+	return k.events
+}
+
+// Run scans the keypad until stop is closed
+func (k *Keypad) Run(stop <-chan struct{}) {
+	ticker := time.NewTicker(k.config.ScanInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+		k.Scan(systemTime())
+	}
+}
+
+// Scan reads all keys once and emits debounced changes.
+// It is called by Run, or can be called directly with a custom time source.
+func (k *Keypad) Scan(now time.Duration) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	scan := k.matrix()
+	for r, row := range k.rows {
+		row.Output()
+		k.delay(10) // settle of column lines
+		for c, column := range k.columns {
+			scan[r][c] = column.Read() == Low
+		}
+		row.Input()
+	}
+
+	k.ghosting = keypadGhosting(scan)
+	if !k.ghosting {
+		for r := range scan {
+			for c := range scan[r] {
+				if scan[r][c] != k.raw[r][c] {
+					k.raw[r][c] = scan[r][c]
+					k.changed[r][c] = now
+				}
+			}
+		}
+	}
+
+	for r := range k.raw {
+		for c := range k.raw[r] {
+			if k.raw[r][c] != k.state[r][c] && now-k.changed[r][c] >= k.config.DebounceTime {
+				k.state[r][c] = k.raw[r][c]
+				event := KeyEvent{Key: k.config.Keymap[r][c], Row: r, Column: c, Down: k.raw[r][c], Time: now}
+				select {
+				case k.events <- event:
+				default:
+				}
+			}
+		}
+	}
+}
+
+// keypadGhosting checks whether pressed keys form a rectangle, two rows with at least two common columns
+func keypadGhosting(scan [][]bool) bool {
+	for r1 := range scan {
+		for r2 := r1 + 1; r2 < len(scan); r2++ {
+			common := 0
+			for c := range scan[r1] {
+				if scan[r1][c] && scan[r2][c] {
+					common++
+				}
+			}
+			if common >= 2 {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Ghosting checks whether the last scan was ignored because of ghosting
+func (k *Keypad) Ghosting() bool {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.ghosting
+}
+
+// Pressed returns keys currently pressed (debounced), by rows
+func (k *Keypad) Pressed() []rune {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	var keys []rune
+	for r := range k.state {
+		for c, down := range k.state[r] {
+			if down {
+				keys = append(keys, k.config.Keymap[r][c])
+			}
+		}
+	}
+	return keys
+}
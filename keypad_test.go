@@ -0,0 +1,176 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+// keypadSim simulates matrix of switches without diodes, a column is Low when it is connected
+// through pressed keys to a row driven Low
+type keypadSim struct {
+	pressed [4][4]bool
+	driven  [4]bool // row is an output
+	pulled  [4]bool // column has pull-up
+}
+
+type keypadSimPin struct {
+	sim   *keypadSim
+	row   bool
+	index int
+}
+
+func (p *keypadSimPin) Input() {
+	if p.row {
+		p.sim.driven[p.index] = false
+	}
+}
+
+func (p *keypadSimPin) Output() {
+	if p.row {
+		p.sim.driven[p.index] = true
+	}
+}
+
+func (p *keypadSimPin) Write(State) {}
+
+func (p *keypadSimPin) PullUp() {
+	p.sim.pulled[p.index] = true
+}
+
+func (p *keypadSimPin) Read() State {
+	s := p.sim
+	// flood from the column through pressed keys
+	var rows, columns [4]bool
+	columns[p.index] = true
+	for changed := true; changed; {
+		changed = false
+		for r := range s.pressed {
+			for c := range s.pressed[r] {
+				if s.pressed[r][c] && rows[r] != columns[c] {
+					rows[r], columns[c] = true, true
+					changed = true
+				}
+			}
+		}
+	}
+	for r := range rows {
+		if rows[r] && s.driven[r] {
+			return Low
+		}
+	}
+	if !s.pulled[p.index] {
+		return Low // floating
+	}
+	return High
+}
+
+func newKeypadSim(t *testing.T) (*keypadSim, *Keypad) {
+	sim := &keypadSim{}
+	var rows, columns []DigitalPin
+	for i := 0; i < 4; i++ {
+		rows = append(rows, &keypadSimPin{sim: sim, row: true, index: i})
+		columns = append(columns, &keypadSimPin{sim: sim, index: i})
+	}
+	k, err := NewKeypad(rows, columns, KeypadConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k.delay = func(uint32) {}
+	return sim, k
+}
+
+func keypadEvents(k *Keypad) (events []KeyEvent) {
+	for {
+		select {
+		case e := <-k.Events():
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+func TestKeypadScan(t *testing.T) {
+	sim, k := newKeypadSim(t)
+	ms := time.Millisecond
+
+	sim.pressed[1][2] = true // '6'
+	k.Scan(0)
+	sim.pressed[1][2] = false // bounce
+	k.Scan(5 * ms)
+	sim.pressed[1][2] = true
+	for now := 10 * ms; now < 30*ms; now += 10 * ms {
+		k.Scan(now)
+	}
+	events := keypadEvents(k)
+	if len(events) != 0 {
+		t.Fatalf("events before debounce time: %v", events)
+	}
+	k.Scan(30 * ms)
+	events = keypadEvents(k)
+	if len(events) != 1 || events[0] != (KeyEvent{Key: '6', Row: 1, Column: 2, Down: true, Time: 30 * ms}) {
+		t.Fatalf("events %v", events)
+	}
+	if keys := k.Pressed(); len(keys) != 1 || keys[0] != '6' {
+		t.Errorf("pressed %q", keys)
+	}
+
+	sim.pressed[1][2] = false
+	sim.pressed[3][0] = true // '*'
+	k.Scan(40 * ms)
+	k.Scan(60 * ms)
+	events = keypadEvents(k)
+	if len(events) != 2 || events[0].Key != '6' || events[0].Down || events[1].Key != '*' || !events[1].Down {
+		t.Errorf("events %v", events)
+	}
+}
+
+func TestKeypadGhosting(t *testing.T) {
+	sim, k := newKeypadSim(t)
+	ms := time.Millisecond
+
+	sim.pressed[0][0] = true // '1'
+	sim.pressed[0][1] = true // '2'
+	k.Scan(0)
+	k.Scan(20 * ms)
+	if k.Ghosting() || len(keypadEvents(k)) != 2 {
+		t.Fatalf("two keys in a row not detected")
+	}
+
+	sim.pressed[1][0] = true // '4', makes '5' look pressed
+	k.Scan(30 * ms)
+	k.Scan(60 * ms)
+	if !k.Ghosting() {
+		t.Errorf("ghosting not detected")
+	}
+	if events := keypadEvents(k); len(events) != 0 {
+		t.Errorf("events while ghosting %v", events)
+	}
+
+	sim.pressed[0][1] = false // release '2', '4' remains
+	k.Scan(70 * ms)
+	k.Scan(90 * ms)
+	events := keypadEvents(k)
+	if k.Ghosting() || len(events) != 2 || events[0].Key != '2' || events[0].Down || events[1].Key != '4' || !events[1].Down {
+		t.Errorf("events %v after ghosting", events)
+	}
+}
+
+func TestKeypadConfig(t *testing.T) {
+	sim := &keypadSim{}
+	pins := func(row bool, n int) (p []DigitalPin) {
+		for i := 0; i < n; i++ {
+			p = append(p, &keypadSimPin{sim: sim, row: row, index: i})
+		}
+		return p
+	}
+	if _, err := NewKeypad(pins(true, 4), pins(false, 4), KeypadConfig{Keymap: Keymap4x3}); err != KeypadConfigError {
+		t.Errorf("4x3 keymap on 4x4 keypad: error %v", err)
+	}
+	if _, err := NewKeypad(pins(true, 4), pins(false, 3), KeypadConfig{Keymap: Keymap4x3}); err != nil {
+		t.Errorf("4x3 keypad: %v", err)
+	}
+	if !sim.pulled[0] || !sim.pulled[2] {
+		t.Errorf("columns not pulled up")
+	}
+}
@@ -0,0 +1,66 @@
+package rpio
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// VideoCore mailbox property interface, accessed through /dev/vcio.
+// See https://github.com/raspberrypi/firmware/wiki/Mailbox-property-interface
+
+// Mailbox property tags
+const (
+	mboxGetClockRate    = 0x00030002
+	mboxGetMaxClockRate = 0x00030004
+	mboxGetMinClockRate = 0x00030007
+)
+
+// Clock ids for clock rate tags
+const (
+	mboxClockCore = 4
+)
+
+var MailboxError = errors.New("mailbox request failed")
+
+// mailboxProperty sends single property tag with given values,
+// returns values of the response (buffer is as long as the request)
+func mailboxProperty(tag uint32, values ...uint32) ([]uint32, error) {
+	file, err := os.OpenFile("/dev/vcio", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	const request = 0
+	const success = 0x80000000
+	const endTag = 0
+
+	buf := make([]uint32, 0, 6+len(values))
+	buf = append(buf, 0, request, tag, uint32(len(values)*4), 0)
+	buf = append(buf, values...)
+	buf = append(buf, endTag)
+	buf[0] = uint32(len(buf) * 4)
+
+	// _IOWR(100, 0, char *)
+	ioctl := uintptr(3<<30 | unsafe.Sizeof(uintptr(0))<<16 | 100<<8 | 0)
+
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, file.Fd(), ioctl, uintptr(unsafe.Pointer(&buf[0])))
+	if errno != 0 {
+		return nil, errno
+	}
+	if buf[1] != success {
+		return nil, MailboxError
+	}
+	return buf[5 : 5+len(values)], nil
+}
+
+// clockRate returns rate [Hz] of given clock, using given tag (current, min or max rate)
+func clockRate(tag uint32, clock uint32) (int, error) {
+	values, err := mailboxProperty(tag, clock, 0)
+	if err != nil {
+		return 0, err
+	}
+	return int(values[1]), nil
+}
@@ -0,0 +1,383 @@
+package rpio
+
+import (
+	"image"
+	"image/color"
+	"time"
+)
+
+// MAX7219 registers
+const (
+	max7219NoOp        = 0x00
+	max7219Digit0      = 0x01 // digits 0 - 7 (matrix rows) follow
+	max7219DecodeMode  = 0x09
+	max7219Intensity   = 0x0a
+	max7219ScanLimit   = 0x0b
+	max7219Shutdown    = 0x0c
+	max7219DisplayTest = 0x0f
+)
+
+// Code B font characters of digits in decode mode, digits 0 - 9 are encoded as themselves
+const (
+	CodeBDash    = 0x0a
+	CodeBE       = 0x0b
+	CodeBH       = 0x0c
+	CodeBL       = 0x0d
+	CodeBP       = 0x0e
+	CodeBBlank   = 0x0f
+	CodeBDecimal = 0x80 // decimal point, or-ed with the character
+)
+
+// MAX7219 is a driver of cascaded MAX7219/MAX7221 LED drivers connected to SPI0,
+// device 0 is the first one in the chain (its DIN is connected to MOSI).
+//
+// Call SpiBegin(Spi0) first. Each write selects the chip, speed and mode (0) of the SPI.
+type MAX7219 struct {
+	devices    int
+	chipSelect uint8
+	speed      int
+	transmit   func(data []byte) // replaces SPI0 in tests
+}
+
+// NewMAX7219 creates driver of given number of cascaded devices on chip select (CE0 - CE2), SPI clock 1MHz
+func NewMAX7219(devices int, chipSelect uint8) *MAX7219 {
+	return &MAX7219{devices: devices, chipSelect: chipSelect, speed: 1000000}
+}
+
+// SetSpeed sets SPI clock [Hz], up to 10MHz
+func (m *MAX7219) SetSpeed(speed int) {
+	m.speed = speed
+}
+
+// Devices returns number of cascaded devices
+func (m *MAX7219) Devices() int {
+	return m.devices
+}
+
+// Init: Wakes up all devices, disables display test and decoding, scans all 8 digits,
+// sets medium intensity and clears the digits.
+func (m *MAX7219) Init() {
+	m.WriteAll(max7219DisplayTest, 0)
+	m.WriteAll(max7219ScanLimit, 7)
+	m.WriteAll(max7219DecodeMode, 0)
+	m.WriteAll(max7219Intensity, 7)
+	m.Clear()
+	m.WriteAll(max7219Shutdown, 1)
+}
+
+func (m *MAX7219) send(data []byte) {
+	if m.transmit != nil {
+		m.transmit(data)
+		return
+	}
+	SpiChipSelect(m.chipSelect)
+	SpiSpeed(m.speed)
+	SpiMode(0, 0)
+	SpiTransmit(data...)
+}
+
+// Write writes register of each device, values[i] to device i. Data are latched by all devices at once.
+// The call is ignored if values are not given for all devices.
+func (m *MAX7219) Write(reg byte, values []byte) {
+	if len(values) < m.devices {
+		return
+	}
+	data := make([]byte, 0, 2*m.devices)
+	for device := m.devices - 1; device >= 0; device-- { // the last device gets the first bytes
+		data = append(data, reg, values[device])
+	}
+	m.send(data)
+}
+
+// WriteAll writes the same value to register of all devices
+func (m *MAX7219) WriteAll(reg, value byte) {
+	values := make([]byte, m.devices)
+	for i := range values {
+		values[i] = value
+	}
+	m.Write(reg, values)
+}
+
+// WriteRegister writes register of single device, others receive no-op.
+// The call is ignored if there is no such device.
+func (m *MAX7219) WriteRegister(device int, reg, value byte) {
+	if device < 0 || device >= m.devices {
+		return
+	}
+	data := make([]byte, 2*m.devices)
+	i := 2 * (m.devices - 1 - device)
+	data[i], data[i+1] = reg, value
+	m.send(data)
+}
+
+// SetIntensity sets brightness of all devices, 0 - 15
+func (m *MAX7219) SetIntensity(intensity byte) {
+	m.WriteAll(max7219Intensity, intensity&0x0f)
+}
+
+// SetScanLimit sets number of displayed digits (1 - 8) of all devices
+func (m *MAX7219) SetScanLimit(digits int) {
+	if digits < 1 {
+		digits = 1
+	}
+	if digits > 8 {
+		digits = 8
+	}
+	m.WriteAll(max7219ScanLimit, byte(digits-1))
+}
+
+// SetDecodeMode selects digits (bit i for digit i) of all devices which are decoded by Code B font,
+// others are written directly as segments (bit 7 DP, bit 6 A ... bit 0 G).
+func (m *MAX7219) SetDecodeMode(digits byte) {
+	m.WriteAll(max7219DecodeMode, digits)
+}
+
+// Shutdown turns off (or back on) all devices, keeping their data
+func (m *MAX7219) Shutdown(shutdown bool) {
+	var on byte = 1
+	if shutdown {
+		on = 0
+	}
+	m.WriteAll(max7219Shutdown, on)
+}
+
+// DisplayTest turns on all segments of all devices
+func (m *MAX7219) DisplayTest(test bool) {
+	var value byte
+	if test {
+		value = 1
+	}
+	m.WriteAll(max7219DisplayTest, value)
+}
+
+// SetDigit writes digit (0 - 7) of device, Code B character or segments depending on decode mode
+func (m *MAX7219) SetDigit(device, digit int, value byte) {
+	m.WriteRegister(device, max7219Digit0+byte(digit&7), value)
+}
+
+// Clear clears all digits of all devices
+func (m *MAX7219) Clear() {
+	for digit := byte(0); digit < 8; digit++ {
+		m.WriteAll(max7219Digit0+digit, 0)
+	}
+}
+
+// MAX7219Matrix is a framebuffer of 8x8 LED matrices driven by cascaded MAX7219,
+// implementing draw.Image. Device 0 shows columns 0 - 7, device 1 columns 8 - 15 and so on,
+// digit registers are rows and bit 7 is the leftmost column.
+//
+// Drawing changes the framebuffer only, call Flush to show it.
+type MAX7219Matrix struct {
+	*MAX7219
+	rows [][8]byte // per device
+}
+
+// NewMAX7219Matrix creates framebuffer for all devices of the driver
+func NewMAX7219Matrix(m *MAX7219) *MAX7219Matrix {
+	return &MAX7219Matrix{MAX7219: m, rows: make([][8]byte, m.devices)}
+}
+
+var max7219Palette = color.Palette{color.Black, color.White}
+
+// ColorModel returns black and white palette
+func (f *MAX7219Matrix) ColorModel() color.Model {
+	return max7219Palette
+}
+
+// Bounds returns (0, 0) - (8*devices, 8)
+func (f *MAX7219Matrix) Bounds() image.Rectangle {
+	return image.Rect(0, 0, 8*f.devices, 8)
+}
+
+// At returns color.White for lit pixel
+func (f *MAX7219Matrix) At(x, y int) color.Color {
+	if !(image.Point{x, y}.In(f.Bounds())) {
+		return color.Black
+	}
+	if f.rows[x/8][y]&(0x80>>uint(x%8)) != 0 {
+		return color.White
+	}
+	return color.Black
+}
+
+// Set lights pixel for colors closer to white than to black
+func (f *MAX7219Matrix) Set(x, y int, c color.Color) {
+	if !(image.Point{x, y}.In(f.Bounds())) {
+		return
+	}
+	bit := byte(0x80 >> uint(x%8))
+	if max7219Palette.Index(c) == 1 {
+		f.rows[x/8][y] |= bit
+	} else {
+		f.rows[x/8][y] &^= bit
+	}
+}
+
+// Fill clears (or lights) all pixels
+func (f *MAX7219Matrix) Fill(on bool) {
+	var value byte
+	if on {
+		value = 0xff
+	}
+	for d := range f.rows {
+		for r := range f.rows[d] {
+			f.rows[d][r] = value
+		}
+	}
+}
+
+// Flush writes the framebuffer to the devices
+func (f *MAX7219Matrix) Flush() {
+	values := make([]byte, f.devices)
+	for row := 0; row < 8; row++ {
+		for d := range values {
+			values[d] = f.rows[d][row]
+		}
+		f.Write(max7219Digit0+byte(row), values)
+	}
+}
+
+// DrawText draws text in built-in 5x7 font with its left edge at column x (may be negative),
+// columns out of the matrix are clipped. Returns width of the text in columns.
+func (f *MAX7219Matrix) DrawText(text string, x int) int {
+	columns := TextColumns(text)
+	for i, column := range columns {
+		for y := 0; y < 8; y++ {
+			c := color.Color(color.Black)
+			if column&(1<<uint(y)) != 0 {
+				c = color.White
+			}
+			f.Set(x+i, y, c)
+		}
+	}
+	return len(columns)
+}
+
+// ScrollText scrolls text from right to left across the matrix, shifting by one column every step.
+// Blocks until the text leaves the matrix.
+func (f *MAX7219Matrix) ScrollText(text string, step time.Duration) {
+	width := f.Bounds().Dx()
+	length := len(TextColumns(text))
+	for x := width; x >= -length; x-- {
+		f.Fill(false)
+		f.DrawText(text, x)
+		f.Flush()
+		time.Sleep(step)
+	}
+}
+
+// TextColumns renders text in built-in 5x7 font to columns (bit 0 is the top row),
+// characters are separated by one blank column. Characters outside of printable ASCII are rendered as '?'.
+func TextColumns(text string) []byte {
+	var columns []byte
+	for i, r := range []rune(text) {
+		if r < 0x20 || r > 0x7e {
+			r = '?'
+		}
+		if i > 0 {
+			columns = append(columns, 0)
+		}
+		glyph := font5x7[(r-0x20)*5:]
+		columns = append(columns, glyph[:5]...)
+	}
+	return columns
+}
+
+// font5x7 contains columns of ASCII 0x20 - 0x7e, bit 0 is the top row
+var font5x7 = []byte{
+	0x00, 0x00, 0x00, 0x00, 0x00, // ' '
+	0x00, 0x00, 0x5f, 0x00, 0x00, // !
+	0x00, 0x07, 0x00, 0x07, 0x00, // "
+	0x14, 0x7f, 0x14, 0x7f, 0x14, // #
+	0x24, 0x2a, 0x7f, 0x2a, 0x12, // $
+	0x23, 0x13, 0x08, 0x64, 0x62, // %
+	0x36, 0x49, 0x55, 0x22, 0x50, // &
+	0x00, 0x05, 0x03, 0x00, 0x00, // '
+	0x00, 0x1c, 0x22, 0x41, 0x00, // (
+	0x00, 0x41, 0x22, 0x1c, 0x00, // )
+	0x08, 0x2a, 0x1c, 0x2a, 0x08, // *
+	0x08, 0x08, 0x3e, 0x08, 0x08, // +
+	0x00, 0x50, 0x30, 0x00, 0x00, // ,
+	0x08, 0x08, 0x08, 0x08, 0x08, // -
+	0x00, 0x60, 0x60, 0x00, 0x00, // .
+	0x20, 0x10, 0x08, 0x04, 0x02, // /
+	0x3e, 0x51, 0x49, 0x45, 0x3e, // 0
+	0x00, 0x42, 0x7f, 0x40, 0x00, // 1
+	0x42, 0x61, 0x51, 0x49, 0x46, // 2
+	0x21, 0x41, 0x45, 0x4b, 0x31, // 3
+	0x18, 0x14, 0x12, 0x7f, 0x10, // 4
+	0x27, 0x45, 0x45, 0x45, 0x39, // 5
+	0x3c, 0x4a, 0x49, 0x49, 0x30, // 6
+	0x01, 0x71, 0x09, 0x05, 0x03, // 7
+	0x36, 0x49, 0x49, 0x49, 0x36, // 8
+	0x06, 0x49, 0x49, 0x29, 0x1e, // 9
+	0x00, 0x36, 0x36, 0x00, 0x00, // :
+	0x00, 0x56, 0x36, 0x00, 0x00, // ;
+	0x08, 0x14, 0x22, 0x41, 0x00, // <
+	0x14, 0x14, 0x14, 0x14, 0x14, // =
+	0x00, 0x41, 0x22, 0x14, 0x08, // >
+	0x02, 0x01, 0x51, 0x09, 0x06, // ?
+	0x32, 0x49, 0x79, 0x41, 0x3e, // @
+	0x7e, 0x11, 0x11, 0x11, 0x7e, // A
+	0x7f, 0x49, 0x49, 0x49, 0x36, // B
+	0x3e, 0x41, 0x41, 0x41, 0x22, // C
+	0x7f, 0x41, 0x41, 0x22, 0x1c, // D
+	0x7f, 0x49, 0x49, 0x49, 0x41, // E
+	0x7f, 0x09, 0x09, 0x09, 0x01, // F
+	0x3e, 0x41, 0x49, 0x49, 0x7a, // G
+	0x7f, 0x08, 0x08, 0x08, 0x7f, // H
+	0x00, 0x41, 0x7f, 0x41, 0x00, // I
+	0x20, 0x40, 0x41, 0x3f, 0x01, // J
+	0x7f, 0x08, 0x14, 0x22, 0x41, // K
+	0x7f, 0x40, 0x40, 0x40, 0x40, // L
+	0x7f, 0x02, 0x0c, 0x02, 0x7f, // M
+	0x7f, 0x04, 0x08, 0x10, 0x7f, // N
+	0x3e, 0x41, 0x41, 0x41, 0x3e, // O
+	0x7f, 0x09, 0x09, 0x09, 0x06, // P
+	0x3e, 0x41, 0x51, 0x21, 0x5e, // Q
+	0x7f, 0x09, 0x19, 0x29, 0x46, // R
+	0x46, 0x49, 0x49, 0x49, 0x31, // S
+	0x01, 0x01, 0x7f, 0x01, 0x01, // T
+	0x3f, 0x40, 0x40, 0x40, 0x3f, // U
+	0x1f, 0x20, 0x40, 0x20, 0x1f, // V
+	0x3f, 0x40, 0x38, 0x40, 0x3f, // W
+	0x63, 0x14, 0x08, 0x14, 0x63, // X
+	0x07, 0x08, 0x70, 0x08, 0x07, // Y
+	0x61, 0x51, 0x49, 0x45, 0x43, // Z
+	0x00, 0x7f, 0x41, 0x41, 0x00, // [
+	0x02, 0x04, 0x08, 0x10, 0x20, // \
+	0x00, 0x41, 0x41, 0x7f, 0x00, // ]
+	0x04, 0x02, 0x01, 0x02, 0x04, // ^
+	0x40, 0x40, 0x40, 0x40, 0x40, // _
+	0x00, 0x01, 0x02, 0x04, 0x00, // `
+	0x20, 0x54, 0x54, 0x54, 0x78, // a
+	0x7f, 0x48, 0x44, 0x44, 0x38, // b
+	0x38, 0x44, 0x44, 0x44, 0x20, // c
+	0x38, 0x44, 0x44, 0x48, 0x7f, // d
+	0x38, 0x54, 0x54, 0x54, 0x18, // e
+	0x08, 0x7e, 0x09, 0x01, 0x02, // f
+	0x0c, 0x52, 0x52, 0x52, 0x3e, // g
+	0x7f, 0x08, 0x04, 0x04, 0x78, // h
+	0x00, 0x44, 0x7d, 0x40, 0x00, // i
+	0x20, 0x40, 0x44, 0x3d, 0x00, // j
+	0x7f, 0x10, 0x28, 0x44, 0x00, // k
+	0x00, 0x41, 0x7f, 0x40, 0x00, // l
+	0x7c, 0x04, 0x18, 0x04, 0x78, // m
+	0x7c, 0x08, 0x04, 0x04, 0x78, // n
+	0x38, 0x44, 0x44, 0x44, 0x38, // o
+	0x7c, 0x14, 0x14, 0x14, 0x08, // p
+	0x08, 0x14, 0x14, 0x18, 0x7c, // q
+	0x7c, 0x08, 0x04, 0x04, 0x08, // r
+	0x48, 0x54, 0x54, 0x54, 0x20, // s
+	0x04, 0x3f, 0x44, 0x40, 0x20, // t
+	0x3c, 0x40, 0x40, 0x20, 0x7c, // u
+	0x1c, 0x20, 0x40, 0x20, 0x1c, // v
+	0x3c, 0x40, 0x30, 0x40, 0x3c, // w
+	0x44, 0x28, 0x10, 0x28, 0x44, // x
+	0x0c, 0x50, 0x50, 0x50, 0x3c, // y
+	0x44, 0x64, 0x54, 0x4c, 0x44, // z
+	0x00, 0x08, 0x36, 0x41, 0x00, // {
+	0x00, 0x00, 0x7f, 0x00, 0x00, // |
+	0x00, 0x41, 0x36, 0x08, 0x00, // }
+	0x02, 0x01, 0x02, 0x04, 0x02, // ~
+}
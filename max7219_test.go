@@ -0,0 +1,98 @@
+package rpio
+
+import (
+	"bytes"
+	"image"
+	"image/color"
+	"image/draw"
+	"testing"
+)
+
+func TestMAX7219Registers(t *testing.T) {
+	var sent [][]byte
+	m := NewMAX7219(3, 0)
+	m.transmit = func(data []byte) { sent = append(sent, append([]byte(nil), data...)) }
+
+	m.SetIntensity(0x1f)
+	m.SetDigit(0, 2, 5|CodeBDecimal)
+	m.SetDigit(2, 7, CodeBDash)
+	m.Write(max7219ScanLimit, []byte{1, 2, 3})
+
+	want := [][]byte{
+		{0x0a, 0x0f, 0x0a, 0x0f, 0x0a, 0x0f},
+		{0x00, 0x00, 0x00, 0x00, 0x03, 0x85}, // device 0 is the last in the stream
+		{0x08, 0x0a, 0x00, 0x00, 0x00, 0x00},
+		{0x0b, 0x03, 0x0b, 0x02, 0x0b, 0x01},
+	}
+	if len(sent) != len(want) {
+		t.Fatalf("sent % x, want % x", sent, want)
+	}
+	for i := range want {
+		if !bytes.Equal(sent[i], want[i]) {
+			t.Errorf("transmission %d: % x, want % x", i, sent[i], want[i])
+		}
+	}
+
+	sent = nil
+	m.Write(max7219ScanLimit, []byte{1, 2}) // missing device
+	m.WriteRegister(3, max7219Intensity, 1)
+	m.WriteRegister(-1, max7219Intensity, 1)
+	if len(sent) != 0 {
+		t.Errorf("invalid writes sent % x", sent)
+	}
+
+	m.Init()
+	if len(sent) != 13 || sent[12][0] != max7219Shutdown || sent[12][1] != 1 {
+		t.Errorf("init sent % x", sent)
+	}
+}
+
+func TestMAX7219Matrix(t *testing.T) {
+	var sent [][]byte
+	m := NewMAX7219(2, 0)
+	m.transmit = func(data []byte) { sent = append(sent, append([]byte(nil), data...)) }
+
+	var f draw.Image = NewMAX7219Matrix(m)
+	if b := f.Bounds(); b != image.Rect(0, 0, 16, 8) {
+		t.Errorf("bounds %v", b)
+	}
+	f.Set(0, 0, color.White)
+	f.Set(9, 0, color.Gray{0xc0})
+	f.Set(15, 7, color.White)
+	f.Set(16, 7, color.White) // out of bounds
+	if f.At(9, 0) != color.White || f.At(8, 0) != color.Black {
+		t.Errorf("pixels not set")
+	}
+	f.(*MAX7219Matrix).Flush()
+
+	if len(sent) != 8 {
+		t.Fatalf("%d transmissions, want 8", len(sent))
+	}
+	if want := []byte{0x01, 0x40, 0x01, 0x80}; !bytes.Equal(sent[0], want) {
+		t.Errorf("row 0: % x, want % x", sent[0], want)
+	}
+	if want := []byte{0x08, 0x01, 0x08, 0x00}; !bytes.Equal(sent[7], want) {
+		t.Errorf("row 7: % x, want % x", sent[7], want)
+	}
+}
+
+func TestMAX7219Text(t *testing.T) {
+	if len(font5x7) != 95*5 {
+		t.Fatalf("font has %d bytes", len(font5x7))
+	}
+	if columns := TextColumns("Hi"); !bytes.Equal(columns, []byte{0x7f, 0x08, 0x08, 0x08, 0x7f, 0, 0x00, 0x44, 0x7d, 0x40, 0x00}) {
+		t.Errorf("columns % x", columns)
+	}
+
+	m := NewMAX7219(1, 0)
+	m.transmit = func(data []byte) {}
+	f := NewMAX7219Matrix(m)
+	if width := f.DrawText("1", 2); width != 5 {
+		t.Errorf("width %d", width)
+	}
+	// '1' is 0x00, 0x42, 0x7f, 0x40, 0x00 from column 2
+	want := [8]byte{0x08, 0x18, 0x08, 0x08, 0x08, 0x08, 0x1c, 0x00}
+	if f.rows[0] != want {
+		t.Errorf("rows % x, want % x", f.rows[0], want)
+	}
+}
@@ -0,0 +1,265 @@
+package rpio
+
+import (
+	"errors"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// MCP3xxxModel selects number of channels and resolution of the ADC
+type MCP3xxxModel uint8
+
+const (
+	MCP3004 MCP3xxxModel = iota // 4 channels, 10 bits
+	MCP3008                     // 8 channels, 10 bits
+	MCP3204                     // 4 channels, 12 bits
+	MCP3208                     // 8 channels, 12 bits
+)
+
+var (
+	AdcChannelError = errors.New("invalid ADC channel")
+	AdcConfigError  = errors.New("invalid ADC stream configuration")
+)
+
+// MCP3xxx is a driver of MCP3004/3008/3204/3208 ADC connected to SPI0.
+//
+// Call SpiBegin(Spi0) first. Each conversion selects the chip, speed and mode (0) of the SPI,
+// so other devices can share the bus.
+type MCP3xxx struct {
+	model      MCP3xxxModel
+	chipSelect uint8
+	speed      int
+	exchange   func(data []byte) // replaces SPI0 in tests
+	mu         sync.Mutex
+}
+
+// NewMCP3xxx creates driver of the ADC on given chip select (CE0 - CE2) with SPI clock 1MHz,
+// which is safe for both 3.3V and 5V supply.
+func NewMCP3xxx(model MCP3xxxModel, chipSelect uint8) *MCP3xxx {
+	return &MCP3xxx{model: model, chipSelect: chipSelect, speed: 1000000}
+}
+
+// SetSpeed sets SPI clock [Hz], MCP300x support up to 3.6MHz, MCP320x up to 2MHz (at 5V)
+func (a *MCP3xxx) SetSpeed(speed int) {
+	a.speed = speed
+}
+
+// Channels returns number of single-ended channels
+func (a *MCP3xxx) Channels() int {
+	if a.model == MCP3004 || a.model == MCP3204 {
+		return 4
+	}
+	return 8
+}
+
+// Bits returns resolution of the ADC
+func (a *MCP3xxx) Bits() int {
+	if a.model == MCP3004 || a.model == MCP3008 {
+		return 10
+	}
+	return 12
+}
+
+// Read converts single-ended channel (0 - 7)
+func (a *MCP3xxx) Read(channel int) (uint16, error) {
+	return a.convert(true, channel)
+}
+
+// ReadDifferential converts differential pair of channels:
+//
+//	channel | 0    | 1    | 2    | 3    | 4    | 5    | 6    | 7
+//	IN+     | CH0  | CH1  | CH2  | CH3  | CH4  | CH5  | CH6  | CH7
+//	IN-     | CH1  | CH0  | CH3  | CH2  | CH5  | CH4  | CH7  | CH6
+func (a *MCP3xxx) ReadDifferential(channel int) (uint16, error) {
+	return a.convert(false, channel)
+}
+
+// Voltage converts raw value to voltage with given reference voltage
+func (a *MCP3xxx) Voltage(value uint16, vref float64) float64 {
+	return float64(value) * vref / float64(uint(1)<<uint(a.Bits()))
+}
+
+func (a *MCP3xxx) convert(single bool, channel int) (uint16, error) {
+	if channel < 0 || channel >= a.Channels() {
+		return 0, AdcChannelError
+	}
+	data := mcp3xxxCommand(a.model, single, channel)
+
+	a.mu.Lock()
+	if a.exchange != nil {
+		a.exchange(data)
+	} else {
+		SpiChipSelect(a.chipSelect)
+		SpiSpeed(a.speed)
+		SpiMode(0, 0)
+		SpiExchange(data)
+	}
+	a.mu.Unlock()
+
+	return mcp3xxxResult(a.model, data), nil
+}
+
+// mcp3xxxCommand encodes conversion request, 3 bytes with start bit aligned
+// so the result ends in the last byte
+func mcp3xxxCommand(model MCP3xxxModel, single bool, channel int) []byte {
+	var sgl byte
+	if single {
+		sgl = 1
+	}
+	ch := byte(channel) & 7
+
+	if model == MCP3004 || model == MCP3008 {
+		// start | sgl d2 d1 d0 x x x x | x
+		return []byte{0x01, sgl<<7 | ch<<4, 0}
+	}
+	// x x x x x start sgl d2 | d1 d0 x x x x x x | x
+	return []byte{0x04 | sgl<<1 | ch>>2, ch << 6, 0}
+}
+
+// mcp3xxxResult decodes conversion result from received bytes
+func mcp3xxxResult(model MCP3xxxModel, data []byte) uint16 {
+	if model == MCP3004 || model == MCP3008 {
+		return uint16(data[1]&0x03)<<8 | uint16(data[2])
+	}
+	return uint16(data[1]&0x0f)<<8 | uint16(data[2])
+}
+
+// AdcSample is a timestamped conversion result
+type AdcSample struct {
+	Channel int
+	Value   uint16
+	Time    time.Duration // system timer, see SystemTime
+}
+
+// AdcStreamConfig: Settings of AdcStream, zero BufferSize means 1024 samples
+type AdcStreamConfig struct {
+	Channels     []int   // channels (or differential pairs) converted in each period
+	Rate         float64 // [Hz] periods per second
+	BufferSize   int     // samples kept in ring buffer
+	Differential bool    // convert differential pairs instead of single-ended channels
+}
+
+// AdcStream samples channels at fixed rate into a ring buffer, see MCP3xxx.Stream
+type AdcStream struct {
+	adc    *MCP3xxx
+	config AdcStreamConfig
+	period time.Duration
+
+	mu      sync.Mutex
+	ring    []AdcSample
+	head    int // index of oldest sample
+	count   int
+	dropped int // samples overwritten before read
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+// Stream: Starts sampling channels in background goroutine, call Stop when done.
+//
+// Periods are scheduled on the system timer, so the rate does not drift,
+// but single samples may be delayed when the goroutine is preempted.
+// When the buffer is full the oldest samples are overwritten.
+func (a *MCP3xxx) Stream(config AdcStreamConfig) (*AdcStream, error) {
+	if config.BufferSize == 0 {
+		config.BufferSize = 1024
+	}
+	if len(config.Channels) == 0 || config.Rate <= 0 || config.BufferSize < 0 {
+		return nil, AdcConfigError
+	}
+	for _, ch := range config.Channels {
+		if ch < 0 || ch >= a.Channels() {
+			return nil, AdcChannelError
+		}
+	}
+
+	s := newAdcStream(a, config)
+	s.stop = make(chan struct{})
+	s.done = make(chan struct{})
+	go s.run()
+	return s, nil
+}
+
+func newAdcStream(adc *MCP3xxx, config AdcStreamConfig) *AdcStream {
+	return &AdcStream{
+		adc:    adc,
+		config: config,
+		period: time.Duration(float64(time.Second) / config.Rate),
+		ring:   make([]AdcSample, config.BufferSize),
+	}
+}
+
+func (s *AdcStream) run() {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+	defer close(s.done)
+
+	next := systemTime()
+	for {
+		select {
+		case <-s.stop:
+			return
+		default:
+		}
+
+		s.sample(next)
+		next += s.period
+		if wait := next - systemTime(); wait > 0 {
+			DelayMicroseconds(uint32(wait / time.Microsecond))
+		} else if wait < -s.period { // too late, skip periods
+			next = systemTime()
+		}
+	}
+}
+
+// sample converts all channels once
+func (s *AdcStream) sample(now time.Duration) {
+	for _, ch := range s.config.Channels {
+		value, _ := s.adc.convert(!s.config.Differential, ch)
+		s.push(AdcSample{Channel: ch, Value: value, Time: now})
+	}
+}
+
+func (s *AdcStream) push(sample AdcSample) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.count == len(s.ring) {
+		s.head = (s.head + 1) % len(s.ring)
+		s.count--
+		s.dropped++
+	}
+	s.ring[(s.head+s.count)%len(s.ring)] = sample
+	s.count++
+}
+
+// Samples removes and returns buffered samples, oldest first
+func (s *AdcStream) Samples() []AdcSample {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	samples := make([]AdcSample, s.count)
+	for i := range samples {
+		samples[i] = s.ring[(s.head+i)%len(s.ring)]
+	}
+	s.head = (s.head + s.count) % len(s.ring)
+	s.count = 0
+	return samples
+}
+
+// Dropped returns number of samples overwritten before they were read
+func (s *AdcStream) Dropped() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.dropped
+}
+
+// Stop stops sampling, buffered samples can still be read
+func (s *AdcStream) Stop() {
+	if s.stop != nil {
+		close(s.stop)
+		<-s.done
+		s.stop = nil
+	}
+}
@@ -0,0 +1,83 @@
+package rpio
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+func TestMCP3xxxCommands(t *testing.T) {
+	tests := []struct {
+		model   MCP3xxxModel
+		single  bool
+		channel int
+		command []byte
+		reply   []byte
+		value   uint16
+	}{
+		{MCP3008, true, 0, []byte{0x01, 0x80, 0x00}, []byte{0xff, 0xfb, 0x2a}, 0x32a},
+		{MCP3008, true, 7, []byte{0x01, 0xf0, 0x00}, []byte{0xff, 0xf8, 0x01}, 0x001},
+		{MCP3008, false, 1, []byte{0x01, 0x10, 0x00}, []byte{0xff, 0xf9, 0xff}, 0x1ff},
+		{MCP3208, true, 0, []byte{0x06, 0x00, 0x00}, []byte{0xff, 0xef, 0xff}, 0xfff},
+		{MCP3208, true, 5, []byte{0x07, 0x40, 0x00}, []byte{0xff, 0xe1, 0x23}, 0x123},
+		{MCP3204, false, 2, []byte{0x04, 0x80, 0x00}, []byte{0xff, 0xe8, 0x00}, 0x800},
+	}
+	for _, test := range tests {
+		var sent []byte
+		adc := NewMCP3xxx(test.model, 0)
+		adc.exchange = func(data []byte) {
+			sent = append([]byte(nil), data...)
+			copy(data, test.reply)
+		}
+
+		read := adc.Read
+		if !test.single {
+			read = adc.ReadDifferential
+		}
+		value, err := read(test.channel)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(sent, test.command) {
+			t.Errorf("model %d channel %d: command % x, want % x", test.model, test.channel, sent, test.command)
+		}
+		if value != test.value {
+			t.Errorf("model %d channel %d: value %#x, want %#x", test.model, test.channel, value, test.value)
+		}
+	}
+
+	if _, err := NewMCP3xxx(MCP3004, 0).Read(4); err != AdcChannelError {
+		t.Errorf("error %v, want %v", err, AdcChannelError)
+	}
+	if v := NewMCP3xxx(MCP3208, 0).Voltage(2048, 3.3); v != 1.65 {
+		t.Errorf("voltage %v, want 1.65", v)
+	}
+}
+
+func TestAdcStreamRing(t *testing.T) {
+	adc := NewMCP3xxx(MCP3008, 0)
+	var value byte
+	adc.exchange = func(data []byte) {
+		value++
+		data[1], data[2] = 0, value
+	}
+	s := newAdcStream(adc, AdcStreamConfig{Channels: []int{0, 3}, Rate: 100, BufferSize: 5})
+
+	for i := 0; i < 4; i++ {
+		s.sample(s.period * time.Duration(i))
+	}
+	samples := s.Samples()
+	if len(samples) != 5 || s.Dropped() != 3 {
+		t.Fatalf("%d samples, %d dropped, want 5 and 3", len(samples), s.Dropped())
+	}
+	want := []AdcSample{{3, 4, 10 * time.Millisecond}, {0, 5, 20 * time.Millisecond}, {3, 6, 20 * time.Millisecond},
+		{0, 7, 30 * time.Millisecond}, {3, 8, 30 * time.Millisecond}}
+	for i := range want {
+		if samples[i] != want[i] {
+			t.Errorf("sample %d: %+v, want %+v", i, samples[i], want[i])
+		}
+	}
+	if n := len(s.Samples()); n != 0 {
+		t.Errorf("%d samples after drain", n)
+	}
+}
@@ -0,0 +1,210 @@
+package rpio
+
+import (
+	"errors"
+	"time"
+)
+
+// AUX registers
+const (
+	auxEnablesReg = 0x04 / 4
+	auxMuIoReg    = 0x40 / 4 // data
+	auxMuIerReg   = 0x44 / 4 // interrupt enable
+	auxMuIirReg   = 0x48 / 4 // interrupt identify, fifo clear
+	auxMuLcrReg   = 0x4c / 4 // line control
+	auxMuMcrReg   = 0x50 / 4 // modem control
+	auxMuLsrReg   = 0x54 / 4 // line status
+	auxMuCntlReg  = 0x60 / 4 // extra control
+	auxMuBaudReg  = 0x68 / 4
+)
+
+// AUX_MU_LSR bits
+const (
+	auxMuDataReady = 1 << 0
+	auxMuOverrun   = 1 << 1
+	auxMuTxEmpty   = 1 << 5 // tx fifo can accept at least one byte
+	auxMuTxIdle    = 1 << 6
+)
+
+var (
+	MiniUartUnsupportedError = errors.New("mini UART is not supported on this chip")
+	MiniUartMapError         = errors.New("AUX registers are not mapped, /dev/mem is required")
+
+	// MiniUartClockWarning is returned by MiniUartBegin along with working MiniUart
+	MiniUartClockWarning = errors.New("core clock is not fixed, mini UART baud rate drifts with its scaling (add enable_uart=1 to config.txt)")
+)
+
+// MiniUartConfig: Settings of MiniUart, zero value of DataBits means 8
+type MiniUartConfig struct {
+	BaudRate int
+	DataBits int // 7 or 8
+
+	// Read waits at most ReadTimeout for first byte to arrive,
+	// zero means wait forever
+	ReadTimeout time.Duration
+}
+
+// MiniUart is the auxiliary UART1, implementing io.ReadWriteCloser
+//
+// The mini uart is clocked from the core (VPU) clock, so its baud rate drifts
+// when the core clock is scaled. Fix the core clock by adding `enable_uart=1`
+// (or `core_freq` and `core_freq_min`) to `/boot/config.txt`.
+type MiniUart struct {
+	config    MiniUartConfig
+	coreFreq  int
+	clockSafe bool
+}
+
+// MiniUartBegin: Enables the mini uart, sets pins 14 (TxD) and 15 (RxD) to UART1 mode
+// and sets baud rate according to current core clock.
+//
+// The baud rate drifts if the core clock is not fixed, the uart is then returned
+// with MiniUartClockWarning, see CoreClock.
+func MiniUartBegin(config MiniUartConfig) (*MiniUart, error) {
+	if !currentChip.Has(PeriphAux) {
+		return nil, MiniUartUnsupportedError
+	}
+	if !devMem {
+		// /dev/gpiomem maps GPIO registers instead, enabling the uart would change pin modes
+		return nil, MiniUartMapError
+	}
+	if config.DataBits == 0 {
+		config.DataBits = 8
+	}
+	if config.BaudRate <= 0 || (config.DataBits != 7 && config.DataBits != 8) {
+		return nil, UartConfigError
+	}
+
+	u := &MiniUart{config: config}
+	u.coreFreq, u.clockSafe = coreClock()
+
+	baud := miniUartDivisor(u.coreFreq, config.BaudRate)
+	if baud > 0xffff {
+		return nil, UartConfigError
+	}
+
+	const enableMiniUart = 1 << 0
+	const rxEnable = 1 << 0
+	const txEnable = 1 << 1
+	const clearFifos = 0xc6
+
+	auxMem[auxEnablesReg] |= enableMiniUart // keep spi1/spi2 enable bits
+	auxMem[auxMuCntlReg] = 0
+	auxMem[auxMuIerReg] = 0 // polling, no interrupts
+	auxMem[auxMuLcrReg] = miniUartLineControl(config.DataBits)
+	auxMem[auxMuMcrReg] = 0
+	auxMem[auxMuIirReg] = clearFifos
+	auxMem[auxMuBaudReg] = baud
+
+	Pin(14).Mode(Alt5)
+	Pin(15).Mode(Alt5)
+
+	auxMem[auxMuCntlReg] = rxEnable | txEnable
+
+	if !u.clockSafe {
+		return u, MiniUartClockWarning
+	}
+	return u, nil
+}
+
+// CoreClock returns the core clock [Hz] used to compute the baud rate
+// and whether the clock is fixed.
+func (u *MiniUart) CoreClock() (freq int, fixed bool) {
+	return u.coreFreq, u.clockSafe
+}
+
+// Read reads available bytes into p, waiting up to ReadTimeout for the first one.
+//
+// Returns TimeoutError if nothing was received, or UartOverrunError if rx fifo overflowed.
+func (u *MiniUart) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	deadline := time.Now().Add(u.config.ReadTimeout)
+	for auxMem[auxMuLsrReg]&auxMuDataReady == 0 {
+		if u.config.ReadTimeout > 0 && time.Now().After(deadline) {
+			return 0, TimeoutError
+		}
+		time.Sleep(time.Microsecond * 100)
+	}
+
+	for n < len(p) {
+		lsr := auxMem[auxMuLsrReg] // reading clears the overrun flag
+		if lsr&auxMuOverrun != 0 {
+			err = UartOverrunError
+		}
+		if lsr&auxMuDataReady == 0 {
+			break
+		}
+		p[n] = byte(auxMem[auxMuIoReg])
+		n++
+	}
+	return n, err
+}
+
+// Write sends all bytes of p, blocks while tx fifo is full
+func (u *MiniUart) Write(p []byte) (n int, err error) {
+	for _, b := range p {
+		for auxMem[auxMuLsrReg]&auxMuTxEmpty == 0 {
+		}
+		auxMem[auxMuIoReg] = uint32(b)
+	}
+	return len(p), nil
+}
+
+// Flush waits until all bytes written are transmitted
+func (u *MiniUart) Flush() {
+	for auxMem[auxMuLsrReg]&auxMuTxIdle == 0 {
+		time.Sleep(time.Microsecond * 10)
+	}
+}
+
+// Close: Waits for end of transmission, disables the mini uart
+// and sets its pins to default (Input) mode. See MiniUartBegin.
+func (u *MiniUart) Close() error {
+	const enableMiniUart = 1 << 0
+
+	u.Flush()
+	auxMem[auxMuCntlReg] = 0
+	auxMem[auxEnablesReg] &^= enableMiniUart
+
+	Pin(14).Mode(Input)
+	Pin(15).Mode(Input)
+	return nil
+}
+
+// coreClock reads current core clock from the firmware, and checks whether it is fixed.
+// Falls back to the default core clock of the chip, which is then assumed not fixed.
+func coreClock() (freq int, fixed bool) {
+	freq, err := clockRate(mboxGetClockRate, mboxClockCore)
+	if err != nil {
+		return currentChip.CoreFreq, false
+	}
+	min, err := clockRate(mboxGetMinClockRate, mboxClockCore)
+	if err != nil {
+		return freq, false
+	}
+	max, err := clockRate(mboxGetMaxClockRate, mboxClockCore)
+	if err != nil {
+		return freq, false
+	}
+	return freq, min == max
+}
+
+// miniUartDivisor computes AUX_MU_BAUD value, baud = clk / (8 * (reg + 1))
+func miniUartDivisor(clk, baud int) uint32 {
+	div := (clk + baud*4) / (baud * 8) // rounded
+	if div < 1 {
+		div = 1
+	}
+	return uint32(div - 1)
+}
+
+// miniUartLineControl computes value of AUX_MU_LCR
+func miniUartLineControl(dataBits int) uint32 {
+	if dataBits == 7 {
+		return 0
+	}
+	return 3 // both bits have to be set for 8 bit mode (see datasheet errata)
+}
@@ -0,0 +1,263 @@
+package rpio
+
+import (
+	"errors"
+	"fmt"
+	"runtime"
+)
+
+// ROM commands
+const (
+	oneWireSearchRom = 0xf0
+	oneWireReadRom   = 0x33
+	oneWireMatchRom  = 0x55
+	oneWireSkipRom   = 0xcc
+)
+
+var (
+	OneWireNoPresenceError = errors.New("no 1-Wire device present")
+	OneWireCrcError        = errors.New("1-Wire CRC mismatch")
+	OneWireSearchError     = errors.New("1-Wire search failed, devices stopped responding")
+)
+
+// OneWireAddress is 64-bit ROM code of a 1-Wire device,
+// family code in the lowest byte (as sent on the bus), CRC in the highest one.
+type OneWireAddress uint64
+
+// Family returns family code of the device (0x28 for DS18B20)
+func (a OneWireAddress) Family() byte {
+	return byte(a)
+}
+
+// String formats the address like the w1 kernel driver, eg. 28-00000a1b2c3d
+func (a OneWireAddress) String() string {
+	return fmt.Sprintf("%02x-%012x", a.Family(), uint64(a)>>8&(1<<48-1))
+}
+
+// bytes returns the address in order of transmission
+func (a OneWireAddress) bytes() []byte {
+	b := make([]byte, 8)
+	for i := range b {
+		b[i] = byte(a >> (8 * uint(i)))
+	}
+	return b
+}
+
+// valid checks CRC of the address
+func (a OneWireAddress) valid() bool {
+	b := a.bytes()
+	return a != 0 && OneWireCRC8(b[:7]) == b[7]
+}
+
+// OneWireCRC8 computes Dallas/Maxim CRC-8 (polynomial x^8 + x^5 + x^4 + 1) of data.
+// CRC of data including its CRC byte is zero.
+func OneWireCRC8(data []byte) byte {
+	var crc byte
+	for _, b := range data {
+		crc ^= b
+		for i := 0; i < 8; i++ {
+			if crc&1 != 0 {
+				crc = crc>>1 ^ 0x8c
+			} else {
+				crc >>= 1
+			}
+		}
+	}
+	return crc
+}
+
+// OneWire is bit-banged 1-Wire bus master, the bus has to be pulled up by an external resistor (4.7k).
+//
+// The pin is driven low in Output mode and released in Input mode, so it behaves as open drain.
+// Slots are timed by DelayMicroseconds, but the program can still be preempted in the middle of a slot,
+// transfers are therefore protected by CRC (see OneWireCRC8) and should be retried on failure.
+type OneWire struct {
+	pin   DigitalPin
+	delay func(us uint32)
+}
+
+// NewOneWire creates 1-Wire master on given pin and releases the bus
+func NewOneWire(pin DigitalPin) *OneWire {
+	w := &OneWire{pin: pin, delay: DelayMicroseconds}
+	w.release()
+	return w
+}
+
+func (w *OneWire) low() {
+	w.pin.Write(Low)
+	w.pin.Output()
+}
+
+func (w *OneWire) release() {
+	w.pin.Input()
+}
+
+// Reset sends reset pulse, returns OneWireNoPresenceError if no device responds with presence pulse
+func (w *OneWire) Reset() error {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	w.low()
+	w.delay(480)
+	w.release()
+	w.delay(70)
+	presence := w.pin.Read() == Low
+	w.delay(410)
+
+	if !presence {
+		return OneWireNoPresenceError
+	}
+	return nil
+}
+
+// WriteBit writes single bit (lowest bit of bit)
+func (w *OneWire) WriteBit(bit byte) {
+	if bit&1 != 0 {
+		w.low()
+		w.delay(6)
+		w.release()
+		w.delay(64)
+	} else {
+		w.low()
+		w.delay(60)
+		w.release()
+		w.delay(10)
+	}
+}
+
+// ReadBit reads single bit
+func (w *OneWire) ReadBit() byte {
+	w.low()
+	w.delay(6)
+	w.release()
+	w.delay(9)
+	bit := byte(w.pin.Read())
+	w.delay(55)
+	return bit
+}
+
+// Write writes bytes, least significant bit first
+func (w *OneWire) Write(p []byte) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for _, b := range p {
+		for i := uint(0); i < 8; i++ {
+			w.WriteBit(b >> i)
+		}
+	}
+	return len(p), nil
+}
+
+// Read reads bytes, least significant bit first
+func (w *OneWire) Read(p []byte) (int, error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	for n := range p {
+		var b byte
+		for i := uint(0); i < 8; i++ {
+			b |= w.ReadBit() << i
+		}
+		p[n] = b
+	}
+	return len(p), nil
+}
+
+// Select resets the bus and addresses a single device, following commands are for the device only
+func (w *OneWire) Select(address OneWireAddress) error {
+	if err := w.Reset(); err != nil {
+		return err
+	}
+	w.Write(append([]byte{oneWireMatchRom}, address.bytes()...))
+	return nil
+}
+
+// Skip resets the bus and addresses all devices, following commands are for all of them
+func (w *OneWire) Skip() error {
+	if err := w.Reset(); err != nil {
+		return err
+	}
+	w.Write([]byte{oneWireSkipRom})
+	return nil
+}
+
+// ReadAddress reads address of the only device on the bus
+func (w *OneWire) ReadAddress() (OneWireAddress, error) {
+	if err := w.Reset(); err != nil {
+		return 0, err
+	}
+	w.Write([]byte{oneWireReadRom})
+	b := make([]byte, 8)
+	w.Read(b)
+
+	var address OneWireAddress
+	for i := range b {
+		address |= OneWireAddress(b[i]) << (8 * uint(i))
+	}
+	if !address.valid() {
+		return 0, OneWireCrcError
+	}
+	return address, nil
+}
+
+// Search finds addresses of all devices on the bus (Search ROM command, see Maxim AN187)
+func (w *OneWire) Search() ([]OneWireAddress, error) {
+	var found []OneWireAddress
+	var last uint64
+	lastDiscrepancy := -1
+
+	for {
+		if err := w.Reset(); err != nil {
+			return found, err
+		}
+		w.Write([]byte{oneWireSearchRom})
+
+		rom, discrepancy, err := w.searchPass(last, lastDiscrepancy)
+		if err != nil {
+			return found, err
+		}
+		address := OneWireAddress(rom)
+		if !address.valid() {
+			return found, OneWireCrcError
+		}
+		found = append(found, address)
+
+		if discrepancy < 0 {
+			return found, nil
+		}
+		last, lastDiscrepancy = rom, discrepancy
+	}
+}
+
+// searchPass reads one address, taking the branch 1 at last discrepancy and the branches of the last
+// address before it. Returns position of the last branch 0 taken, where next pass continues.
+func (w *OneWire) searchPass(last uint64, lastDiscrepancy int) (rom uint64, discrepancy int, err error) {
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	discrepancy = -1
+	for i := 0; i < 64; i++ {
+		bit := w.ReadBit()
+		complement := w.ReadBit()
+
+		switch {
+		case bit == 1 && complement == 1:
+			return 0, 0, OneWireSearchError
+		case bit != complement: // all remaining devices have the same bit
+		case i < lastDiscrepancy:
+			bit = byte(last >> uint(i) & 1)
+		case i == lastDiscrepancy:
+			bit = 1
+		default:
+			bit = 0
+		}
+		if bit == 0 && complement == 0 {
+			discrepancy = i
+		}
+
+		rom |= uint64(bit) << uint(i)
+		w.WriteBit(bit)
+	}
+	return rom, discrepancy, nil
+}
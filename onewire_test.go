@@ -0,0 +1,391 @@
+package rpio
+
+import (
+	"testing"
+)
+
+// simWire simulates 1-Wire bus with slaves, in virtual time advanced by the master delays
+type simWire struct {
+	now     uint64 // [µs]
+	output  bool
+	latch   State
+	low     bool   // master drives the bus low
+	fall    uint64 // when master started driving low
+	slaves  []*simSlave
+	samples []uint64 // times of master reads, to check timing
+}
+
+func (w *simWire) Input() {
+	w.output = false
+	w.update()
+}
+
+func (w *simWire) Output() {
+	w.output = true
+	w.update()
+}
+
+func (w *simWire) Write(state State) {
+	w.latch = state
+	w.update()
+}
+
+func (w *simWire) Read() State {
+	w.samples = append(w.samples, w.now)
+	if w.low {
+		return Low
+	}
+	for _, s := range w.slaves {
+		if w.now >= s.lowFrom && w.now < s.lowUntil {
+			return Low
+		}
+	}
+	return High
+}
+
+func (w *simWire) delay(us uint32) {
+	w.now += uint64(us)
+}
+
+func (w *simWire) update() {
+	low := w.output && w.latch == Low
+	switch {
+	case low && !w.low:
+		w.fall = w.now
+		for _, s := range w.slaves {
+			s.slotStart(w.now)
+		}
+	case !low && w.low:
+		for _, s := range w.slaves {
+			s.slotEnd(w.fall, w.now)
+		}
+	}
+	w.low = low
+}
+
+// simSlave simulates DS18B20 at bit level
+type simSlave struct {
+	rom        OneWireAddress
+	scratchpad [9]byte
+
+	lowFrom, lowUntil uint64 // slave pulls the bus low
+
+	state    int // one of simXxx
+	rx       []byte
+	rxBits   int
+	rxNeed   int // bytes to receive before handling them
+	tx       []byte
+	txBit    int
+	search   int // bit index during search
+	phase    int // 0 send bit, 1 send complement, 2 receive
+	convEnd  uint64
+	polling  bool // master reads conversion status
+	converts int
+	parasite bool // powered from the bus, needs strong pull-up during conversion
+}
+
+const (
+	simIdle     = iota // dropped, waiting for reset
+	simRom             // receiving rom command
+	simFunction        // receiving function command
+	simSearch
+)
+
+func newSimSlave(rom OneWireAddress, temperature int16) *simSlave {
+	s := &simSlave{rom: rom}
+	s.scratchpad = [9]byte{byte(temperature), byte(temperature >> 8), 0x4b, 0x46, 0x7f, 0xff, 0x0c, 0x10}
+	s.scratchpad[8] = OneWireCRC8(s.scratchpad[:8])
+	return s
+}
+
+func (s *simSlave) reset() {
+	s.state = simRom
+	s.rx, s.rxBits, s.rxNeed = nil, 0, 1
+	s.tx, s.txBit = nil, 0
+	s.polling = false
+}
+
+// sending returns bit to be sent in the current slot, ok false if the slave is receiving
+func (s *simSlave) sending(now uint64) (bit byte, ok bool) {
+	switch {
+	case s.state == simSearch && s.phase < 2:
+		bit = byte(s.rom>>uint(s.search)) & 1
+		return bit ^ byte(s.phase), true
+	case s.txBit < len(s.tx)*8:
+		return s.tx[s.txBit/8] >> uint(s.txBit%8) & 1, true
+	case s.polling:
+		if now < s.convEnd {
+			return 0, true
+		}
+		return 1, true
+	}
+	return 1, false
+}
+
+func (s *simSlave) slotStart(now uint64) {
+	if s.state == simIdle {
+		return
+	}
+	if bit, ok := s.sending(now); ok && bit == 0 {
+		s.lowFrom, s.lowUntil = now, now+30
+	}
+}
+
+func (s *simSlave) slotEnd(fall, now uint64) {
+	pulse := now - fall
+	if pulse >= 400 {
+		s.reset()
+		s.lowFrom, s.lowUntil = now+30, now+150 // presence
+		return
+	}
+	if s.state == simIdle {
+		return
+	}
+
+	if _, ok := s.sending(fall); ok {
+		if s.state == simSearch {
+			s.phase++
+		} else if s.txBit < len(s.tx)*8 {
+			s.txBit++
+		}
+		return
+	}
+
+	bit := byte(0)
+	if pulse < 15 {
+		bit = 1
+	}
+	if s.state == simSearch { // phase 2, master selects branch
+		if bit != byte(s.rom>>uint(s.search))&1 {
+			s.state = simIdle
+			return
+		}
+		s.search++
+		s.phase = 0
+		if s.search == 64 {
+			s.state = simIdle
+		}
+		return
+	}
+
+	if s.rxBits%8 == 0 {
+		s.rx = append(s.rx, 0)
+	}
+	s.rx[len(s.rx)-1] |= bit << uint(s.rxBits%8)
+	s.rxBits++
+	if s.rxBits == s.rxNeed*8 {
+		s.handle(now)
+	}
+}
+
+func (s *simSlave) handle(now uint64) {
+	rx := s.rx
+	s.rx, s.rxBits, s.rxNeed = nil, 0, 1
+
+	switch s.state {
+	case simRom:
+		switch rx[0] {
+		case oneWireSkipRom:
+			s.state = simFunction
+		case oneWireReadRom:
+			s.state = simFunction
+			s.tx = s.rom.bytes()
+		case oneWireMatchRom:
+			if len(rx) == 1 {
+				s.rx, s.rxBits, s.rxNeed = rx, 8, 9 // address follows
+				return
+			}
+			s.state = simIdle
+			if OneWireAddress(uint64(rx[1])|uint64(rx[2])<<8|uint64(rx[3])<<16|uint64(rx[4])<<24|
+				uint64(rx[5])<<32|uint64(rx[6])<<40|uint64(rx[7])<<48|uint64(rx[8])<<56) == s.rom {
+				s.state = simFunction
+			}
+		case oneWireSearchRom:
+			s.state = simSearch
+			s.search, s.phase = 0, 0
+		default:
+			s.state = simIdle
+		}
+
+	case simFunction:
+		switch rx[0] {
+		case ds18b20ReadPower:
+			if s.parasite {
+				s.tx, s.txBit = []byte{0}, 0
+			}
+		case ds18b20Convert:
+			s.converts++
+			s.polling = !s.parasite
+			bits := int(s.scratchpad[4]>>5&3) + 9
+			s.convEnd = now + uint64(ds18b20ConversionTime(bits).Microseconds())
+		case ds18b20ReadScratchpad:
+			s.tx, s.txBit = s.scratchpad[:], 0
+		case ds18b20Write:
+			if len(rx) == 1 {
+				s.rx, s.rxBits, s.rxNeed = rx, 8, 4
+				return
+			}
+			copy(s.scratchpad[2:5], rx[1:4])
+			s.scratchpad[8] = OneWireCRC8(s.scratchpad[:8])
+		}
+	}
+}
+
+// simAddress creates valid address with given family and serial number
+func simAddress(family byte, serial uint64) OneWireAddress {
+	a := OneWireAddress(serial<<8 | uint64(family))
+	b := a.bytes()
+	return a | OneWireAddress(OneWireCRC8(b[:7]))<<56
+}
+
+func TestOneWireCRC8(t *testing.T) {
+	// ROM code from Maxim application note 27
+	rom := []byte{0x02, 0x1c, 0xb8, 0x01, 0x00, 0x00, 0x00}
+	if crc := OneWireCRC8(rom); crc != 0xa2 {
+		t.Errorf("crc %#x, want 0xa2", crc)
+	}
+	if crc := OneWireCRC8(append(rom, 0xa2)); crc != 0 {
+		t.Errorf("crc including crc byte %#x, want 0", crc)
+	}
+}
+
+func TestOneWireResetAndTiming(t *testing.T) {
+	wire := &simWire{}
+	bus := &OneWire{pin: wire, delay: wire.delay}
+	if err := bus.Reset(); err != OneWireNoPresenceError {
+		t.Errorf("empty bus: error %v, want %v", err, OneWireNoPresenceError)
+	}
+
+	address := simAddress(0x28, 0x0a1b2c3d)
+	wire.slaves = []*simSlave{newSimSlave(address, 0x0191)}
+	if err := bus.Reset(); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := bus.ReadAddress(); err != nil || got != address {
+		t.Errorf("read address %v %v, want %v", got, err, address)
+	}
+	if s := address.String(); s != "28-00000a1b2c3d" {
+		t.Errorf("address string %s", s)
+	}
+
+	// every slot takes 70µs, reads sample 15µs after the slot start
+	wire.samples = nil
+	start := wire.now
+	bus.ReadBit()
+	bus.WriteBit(0)
+	bus.WriteBit(1)
+	if len(wire.samples) != 1 || wire.samples[0]-start != 15 || wire.now-start != 3*70 {
+		t.Errorf("slot timing: samples %v from %d, end %d", wire.samples, start, wire.now)
+	}
+}
+
+func TestOneWireSearch(t *testing.T) {
+	addresses := []OneWireAddress{
+		simAddress(0x28, 0x000000000001),
+		simAddress(0x28, 0x800000000001),
+		simAddress(0x28, 0x123456789abc),
+		simAddress(0x10, 0x000000000001),
+	}
+	wire := &simWire{}
+	for _, a := range addresses {
+		wire.slaves = append(wire.slaves, newSimSlave(a, 0))
+	}
+	bus := &OneWire{pin: wire, delay: wire.delay}
+
+	found, err := bus.Search()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != len(addresses) {
+		t.Fatalf("found %v, want %v", found, addresses)
+	}
+	seen := map[OneWireAddress]bool{}
+	for _, a := range found {
+		seen[a] = true
+	}
+	for _, a := range addresses {
+		if !seen[a] {
+			t.Errorf("address %v not found", a)
+		}
+	}
+
+	sensors, _ := FindDS18B20(bus)
+	if len(sensors) != 3 {
+		t.Errorf("found %d DS18B20, want 3", len(sensors))
+	}
+}
+
+func TestDS18B20(t *testing.T) {
+	wire := &simWire{}
+	a1, a2 := simAddress(0x28, 1), simAddress(0x28, 2)
+	s1, s2 := newSimSlave(a1, 0x0191), newSimSlave(a2, -0x0192) // 25.0625, -25.125
+	wire.slaves = []*simSlave{s1, s2}
+	bus := &OneWire{pin: wire, delay: wire.delay}
+
+	d1, d2 := NewDS18B20(bus, a1), NewDS18B20(bus, a2)
+	s2.scratchpad[2], s2.scratchpad[3] = 0x28, 0x05 // alarm thresholds set by user
+	s2.scratchpad[8] = OneWireCRC8(s2.scratchpad[:8])
+	if err := d2.SetResolution(10); err != nil {
+		t.Fatal(err)
+	}
+	if s2.scratchpad[4] != 0x3f || s1.scratchpad[4] != 0x7f {
+		t.Errorf("config registers %#x %#x", s1.scratchpad[4], s2.scratchpad[4])
+	}
+	if s2.scratchpad[2] != 0x28 || s2.scratchpad[3] != 0x05 {
+		t.Errorf("alarm thresholds changed to %#x %#x", s2.scratchpad[2], s2.scratchpad[3])
+	}
+
+	start := wire.now
+	if err := ConvertAll(bus); err != nil {
+		t.Fatal(err)
+	}
+	if s1.converts != 1 || s2.converts != 1 {
+		t.Errorf("conversions %d %d, want parallel conversion", s1.converts, s2.converts)
+	}
+	if waited := wire.now - start; waited < 750000 || waited > 770000 {
+		t.Errorf("conversion waited %dµs", waited)
+	}
+
+	if temp, err := d1.ReadTemperature(); err != nil || temp != 25.0625 {
+		t.Errorf("temperature %v %v, want 25.0625", temp, err)
+	}
+	if temp, err := d2.ReadTemperature(); err != nil || temp != -25.25 { // 10 bits
+		t.Errorf("temperature %v %v, want -25.25", temp, err)
+	}
+
+	s1.scratchpad[0] ^= 1 // corrupt
+	if _, err := d1.ReadTemperature(); err != OneWireCrcError {
+		t.Errorf("corrupted scratchpad: error %v, want %v", err, OneWireCrcError)
+	}
+
+	if err := d2.SetResolution(13); err != DS18B20ResolutionError {
+		t.Errorf("error %v, want %v", err, DS18B20ResolutionError)
+	}
+}
+
+func TestDS18B20Parasite(t *testing.T) {
+	wire := &simWire{}
+	address := simAddress(0x28, 1)
+	sensor := newSimSlave(address, 0x0191)
+	sensor.parasite = true
+	wire.slaves = []*simSlave{sensor}
+	bus := &OneWire{pin: wire, delay: wire.delay}
+	d := NewDS18B20(bus, address)
+	if err := d.SetResolution(9); err != nil {
+		t.Fatal(err)
+	}
+
+	start := wire.now
+	if err := d.Convert(); err != nil {
+		t.Fatal(err)
+	}
+	if waited := wire.now - start; waited < 93750 || waited > 110000 {
+		t.Errorf("conversion waited %dµs, want conversion time", waited)
+	}
+	if wire.output {
+		t.Errorf("bus not released after strong pull-up")
+	}
+	if temp, err := d.ReadTemperature(); err != nil || temp != 25 { // 9 bits
+		t.Errorf("temperature %v %v, want 25", temp, err)
+	}
+}
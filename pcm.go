@@ -0,0 +1,261 @@
+package rpio
+
+import (
+	"encoding/binary"
+	"errors"
+	"time"
+)
+
+// PCM registers
+const (
+	pcmCsReg   = 0 // control and status
+	pcmFifoReg = 1
+	pcmModeReg = 2
+	pcmRxcReg  = 3 // receive channel configuration
+	pcmTxcReg  = 4 // transmit channel configuration
+)
+
+// PCM_CS_A bits
+const (
+	pcmEn    = 1 << 0
+	pcmRxOn  = 1 << 1
+	pcmTxOn  = 1 << 2
+	pcmTxClr = 1 << 3
+	pcmRxClr = 1 << 4
+	pcmTxErr = 1 << 15
+	pcmRxErr = 1 << 16
+	pcmTxd   = 1 << 19 // tx fifo can accept data
+	pcmRxd   = 1 << 20 // rx fifo contains data
+	pcmRxSex = 1 << 23 // sign extend received samples
+	pcmSync  = 1 << 24
+	pcmStby  = 1 << 25 // fifo RAMs out of standby
+)
+
+// pcmTimeout limits waiting for the fifos and the SYNC bit, they do not move when the clock is not running
+const pcmTimeout = 100 * time.Millisecond
+
+// Clock manager registers of PCM clock
+const (
+	pcmClkCtlReg = 38
+	pcmClkDivReg = 39
+)
+
+var (
+	PcmUnsupportedError = errors.New("PCM is not supported on this chip")
+	PcmMapError         = errors.New("PCM registers are not mapped, /dev/mem is required")
+	PcmConfigError      = errors.New("invalid PCM configuration")
+	PcmClockError       = errors.New("PCM clock is not running")
+	PcmOverrunError     = errors.New("PCM receive fifo overrun")
+	PcmUnderrunError    = errors.New("PCM transmit fifo underrun")
+)
+
+// PcmConfig: Settings of Pcm, frames are in I2S format
+// (frame sync low for the left channel, data delayed one bit clock after frame sync edge).
+//
+// Zero values of ChannelWidth, FrameLength and Channels mean 16, 2*ChannelWidth and 2.
+type PcmConfig struct {
+	SampleRate   int  // [Hz] frames per second
+	ChannelWidth int  // bits per sample, 8 - 32
+	FrameLength  int  // bit clocks per frame, at least Channels * ChannelWidth
+	Channels     int  // 1 or 2
+	Slave        bool // bit clock and frame sync are generated by the other device
+}
+
+// Pcm is the PCM/I2S interface, streaming interleaved samples of both channels.
+//
+// It implements io.Reader and io.Writer of frames encoded as little endian int32 samples,
+// use ReadSamples and WriteSamples to avoid the conversion.
+//
+// Samples are transferred by polling the fifos, so the caller has to keep up with the sample rate.
+// Transfers fail with PcmClockError when the fifos do not move for 100ms (no bit clock in slave mode).
+type Pcm struct {
+	config PcmConfig
+}
+
+// PcmBegin: Sets pins 18 (CLK), 19 (FS), 20 (DIN) and 21 (DOUT) to PCM mode,
+// starts PCM clock (in master mode) and enables both receiver and transmitter.
+//
+// Note that the I2S interface should be disabled in `/boot/config.txt` first (no `dtparam=i2s=on`).
+// Requires /dev/mem, returns PcmMapError otherwise.
+func PcmBegin(config PcmConfig) (*Pcm, error) {
+	if !currentChip.Has(PeriphPcm) {
+		return nil, PcmUnsupportedError
+	}
+	if !devMem {
+		// /dev/gpiomem maps GPIO registers instead, writing them would change pin modes
+		return nil, PcmMapError
+	}
+
+	if config.ChannelWidth == 0 {
+		config.ChannelWidth = 16
+	}
+	if config.Channels == 0 {
+		config.Channels = 2
+	}
+	if config.FrameLength == 0 {
+		config.FrameLength = 2 * config.ChannelWidth
+	}
+	if config.SampleRate <= 0 ||
+		config.ChannelWidth < 8 || config.ChannelWidth > 32 ||
+		config.Channels < 1 || config.Channels > 2 ||
+		config.FrameLength < config.Channels*config.ChannelWidth || config.FrameLength > 1024 {
+		return nil, PcmConfigError
+	}
+
+	pcmMem[pcmCsReg] = 0 // disable
+
+	for _, pin := range []Pin{18, 19, 20, 21} {
+		pin.Mode(Alt0)
+	}
+
+	if !config.Slave {
+		setClockFreq(pcmClkCtlReg, pcmClkDivReg, config.SampleRate*config.FrameLength)
+	}
+
+	pcmMem[pcmCsReg] = pcmEn | pcmStby // fifo RAMs need a few pcm clocks to leave standby
+	pcmMem[pcmModeReg] = pcmMode(config)
+	channels := pcmChannels(config)
+	pcmMem[pcmRxcReg] = channels
+	pcmMem[pcmTxcReg] = channels
+
+	pcmMem[pcmCsReg] |= pcmTxClr | pcmRxClr
+	if err := pcmWaitSync(); err != nil { // fifo clear takes 2 pcm clocks
+		pcmDisable()
+		return nil, err
+	}
+
+	pcmMem[pcmCsReg] |= pcmRxSex | pcmRxOn | pcmTxOn
+
+	return &Pcm{config: config}, nil
+}
+
+// ReadSamples receives interleaved samples of whole frames,
+// returns number of samples read (len(samples) rounded down to whole frames, less on PcmClockError).
+func (p *Pcm) ReadSamples(samples []int32) (n int, err error) {
+	total := len(samples) - len(samples)%p.config.Channels
+	for ; n < total; n++ {
+		if !pcmWait(pcmRxd) {
+			return n, PcmClockError
+		}
+		samples[n] = int32(pcmMem[pcmFifoReg])
+	}
+
+	if pcmMem[pcmCsReg]&pcmRxErr != 0 {
+		pcmMem[pcmCsReg] |= pcmRxErr // clear
+		err = PcmOverrunError
+	}
+	return n, err
+}
+
+// WriteSamples transmits interleaved samples of whole frames,
+// returns number of samples written (len(samples) rounded down to whole frames, less on PcmClockError).
+func (p *Pcm) WriteSamples(samples []int32) (n int, err error) {
+	if pcmMem[pcmCsReg]&pcmTxErr != 0 {
+		pcmMem[pcmCsReg] |= pcmTxErr // clear
+		err = PcmUnderrunError
+	}
+
+	total := len(samples) - len(samples)%p.config.Channels
+	for ; n < total; n++ {
+		if !pcmWait(pcmTxd) {
+			return n, PcmClockError
+		}
+		pcmMem[pcmFifoReg] = uint32(samples[n])
+	}
+	return n, err
+}
+
+// Read receives whole frames to b, each sample as 4 bytes little endian int32
+func (p *Pcm) Read(b []byte) (int, error) {
+	samples := make([]int32, len(b)/4)
+	n, err := p.ReadSamples(samples)
+	for i := 0; i < n; i++ {
+		binary.LittleEndian.PutUint32(b[i*4:], uint32(samples[i]))
+	}
+	return n * 4, err
+}
+
+// Write transmits whole frames from b, each sample as 4 bytes little endian int32
+func (p *Pcm) Write(b []byte) (int, error) {
+	samples := make([]int32, len(b)/4)
+	for i := range samples {
+		samples[i] = int32(binary.LittleEndian.Uint32(b[i*4:]))
+	}
+	n, err := p.WriteSamples(samples)
+	if err == nil && n*4 < len(b) {
+		err = PcmConfigError // not a whole number of frames
+	}
+	return n * 4, err
+}
+
+// Close: Disables the PCM interface and sets its pins to default (Input) mode. See PcmBegin.
+func (p *Pcm) Close() error {
+	pcmDisable()
+	return nil
+}
+
+func pcmDisable() {
+	pcmMem[pcmCsReg] = 0
+
+	for _, pin := range []Pin{18, 19, 20, 21} {
+		pin.Mode(Input)
+	}
+}
+
+// pcmWaitSync waits 2 pcm clocks using the SYNC bit
+func pcmWaitSync() error {
+	pcmMem[pcmCsReg] |= pcmSync
+	if !pcmWait(pcmSync) {
+		return PcmClockError
+	}
+	pcmMem[pcmCsReg] &^= pcmSync
+	return nil
+}
+
+// pcmWait waits until given bit of control register is set, at most pcmTimeout
+func pcmWait(bit uint32) bool {
+	var deadline time.Time
+	for pcmMem[pcmCsReg]&bit == 0 {
+		if deadline.IsZero() {
+			deadline = time.Now().Add(pcmTimeout)
+		} else if time.Now().After(deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+// pcmMode computes value of mode register for I2S framing
+func pcmMode(config PcmConfig) uint32 {
+	const fsi = 1 << 20  // frame sync inverted - low for left channel
+	const fsm = 1 << 21  // frame sync is input
+	const clki = 1 << 22 // outputs change on falling edge, inputs sampled on rising edge
+	const clkm = 1 << 23 // clock is input
+
+	mode := uint32(config.FrameLength-1)<<10 | uint32(config.FrameLength/2) | fsi | clki
+	if config.Slave {
+		mode |= fsm | clkm
+	}
+	return mode
+}
+
+// pcmChannels computes value of rx/tx channel configuration registers
+func pcmChannels(config PcmConfig) uint32 {
+	const wex = 1 << 15
+	const en = 1 << 14
+
+	width := uint32(config.ChannelWidth - 8)
+	channel := func(pos int) uint32 {
+		ch := en | uint32(pos)<<4 | width&15
+		if width >= 16 {
+			ch |= wex
+		}
+		return ch
+	}
+
+	channels := channel(1) << 16 // one bit clock delay after frame sync
+	if config.Channels == 2 {
+		channels |= channel(config.FrameLength/2 + 1)
+	}
+	return channels
+}
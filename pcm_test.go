@@ -0,0 +1,62 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPcmRegisters(t *testing.T) {
+	tests := []struct {
+		config   PcmConfig
+		mode     uint32
+		channels uint32
+	}{
+		{PcmConfig{ChannelWidth: 16, FrameLength: 32, Channels: 2}, 0x507c10, 0x40184118},
+		{PcmConfig{ChannelWidth: 32, FrameLength: 64, Channels: 2}, 0x50fc20, 0xc018c218},
+		{PcmConfig{ChannelWidth: 32, FrameLength: 64, Channels: 2, Slave: true}, 0xf0fc20, 0xc018c218},
+		{PcmConfig{ChannelWidth: 24, FrameLength: 64, Channels: 1}, 0x50fc20, 0xc0100000},
+	}
+	for _, test := range tests {
+		if mode := pcmMode(test.config); mode != test.mode {
+			t.Errorf("%+v: mode %#x, want %#x", test.config, mode, test.mode)
+		}
+		if channels := pcmChannels(test.config); channels != test.channels {
+			t.Errorf("%+v: channels %#x, want %#x", test.config, channels, test.channels)
+		}
+	}
+}
+
+func TestPcmClockTimeout(t *testing.T) {
+	saved := pcmMem
+	defer func() { pcmMem = saved }()
+	pcmMem = make([]uint32, 8) // fifos do not move without clock
+
+	p := &Pcm{config: PcmConfig{Channels: 2}}
+	start := time.Now()
+	if n, err := p.WriteSamples(make([]int32, 4)); n != 0 || err != PcmClockError {
+		t.Errorf("write without clock: %d samples, error %v", n, err)
+	}
+	if elapsed := time.Since(start); elapsed < pcmTimeout {
+		t.Errorf("gave up after %v", elapsed)
+	}
+
+	pcmMem[pcmCsReg] = pcmRxd
+	pcmMem[pcmFifoReg] = 0xfffffffe
+	samples := make([]int32, 3)
+	if n, err := p.ReadSamples(samples); n != 2 || err != nil || samples[0] != -2 {
+		t.Errorf("read %d samples %v, error %v", n, samples, err)
+	}
+}
+
+func TestPcmMap(t *testing.T) {
+	saved := pcmMem
+	defer func() { pcmMem = saved }()
+	pcmMem = []uint32{0x1249249, 0, 0, 0, 0} // GPFSEL0 as mapped by /dev/gpiomem
+
+	if _, err := PcmBegin(PcmConfig{SampleRate: 48000}); err != PcmMapError {
+		t.Errorf("without /dev/mem: error %v, want %v", err, PcmMapError)
+	}
+	if pcmMem[pcmCsReg] != 0x1249249 {
+		t.Errorf("registers written without /dev/mem")
+	}
+}
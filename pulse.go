@@ -0,0 +1,190 @@
+package rpio
+
+import (
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// EdgeEvent is a change of pin level, timestamped by the system timer (see SystemTime)
+type EdgeEvent struct {
+	Pin   Pin
+	Level State         // level after the change
+	Time  time.Duration // since start of the system timer
+}
+
+// systemTime returns SystemTime as duration
+func systemTime() time.Duration {
+	return time.Duration(SystemTime()) * time.Microsecond
+}
+
+// MeasurePulse: Measures length of a pulse of given level on input pin (like pulseIn of Arduino).
+//
+// Waits for the pulse to start (if the pin is already at the level, the pulse in progress is skipped),
+// then measures time until the pin returns back.
+// Returns TimeoutError if the whole measurement takes longer than timeout.
+func (pin Pin) MeasurePulse(level State, timeout time.Duration) (time.Duration, error) {
+	return measurePulse(pin, systemTime, level, timeout)
+}
+
+func measurePulse(pin DigitalPin, clock func() time.Duration, level State, timeout time.Duration) (time.Duration, error) {
+	deadline := clock() + timeout
+
+	wait := func(whileLevel State) (time.Duration, error) {
+		for {
+			now := clock()
+			if pin.Read() != whileLevel {
+				return now, nil
+			}
+			if now > deadline {
+				return 0, TimeoutError
+			}
+		}
+	}
+
+	if _, err := wait(level); err != nil { // previous pulse
+		return 0, err
+	}
+	start, err := wait(level ^ 1)
+	if err != nil {
+		return 0, err
+	}
+	end, err := wait(level)
+	if err != nil {
+		return 0, err
+	}
+	return end - start, nil
+}
+
+// WatchEdges: Polls level of input pin and sends its changes to the returned channel,
+// until stop is closed.
+//
+// The polling goroutine keeps one CPU core busy, but changes are timestamped with microsecond precision.
+func WatchEdges(pin Pin, stop <-chan struct{}) <-chan EdgeEvent {
+	events := make(chan EdgeEvent, 1024)
+
+	go func() {
+		runtime.LockOSThread()
+		defer runtime.UnlockOSThread()
+		defer close(events)
+
+		level := ReadPin(pin)
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+			}
+			if l := ReadPin(pin); l != level {
+				level = l
+				select {
+				case events <- EdgeEvent{Pin: pin, Level: level, Time: systemTime()}:
+				case <-stop:
+					return
+				}
+			}
+		}
+	}()
+
+	return events
+}
+
+// PulseCapture continuously measures period, frequency and duty cycle of a signal
+// from its timestamped level changes.
+type PulseCapture struct {
+	mu sync.Mutex
+
+	lastLevel State
+	lastTime  time.Duration
+	edges     int
+
+	high, low time.Duration // length of last high and low pulse
+
+	file *os.File // kernel line events
+	stop chan struct{}
+}
+
+// CapturePulses: Starts capturing pulses of input pin. Call Stop when done.
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available,
+// otherwise the pin is polled in a busy loop (see WatchEdges), which takes one CPU core.
+func CapturePulses(pin Pin) (*PulseCapture, error) {
+	c := &PulseCapture{stop: make(chan struct{})}
+	events, file, err := watchLineEdges(pin, "rpio-capture", c.stop)
+	if err != nil {
+		return nil, err
+	}
+	c.file = file
+	go func() {
+		for event := range events {
+			c.Add(event)
+		}
+	}()
+	return c, nil
+}
+
+// Stop capturing pulses
+func (c *PulseCapture) Stop() {
+	if c.file != nil {
+		c.file.Close()
+		c.file = nil
+	}
+	if c.stop != nil {
+		close(c.stop)
+		c.stop = nil
+	}
+}
+
+// Add processes level change, events have to be added in order.
+// It can be used to feed the capture from any edge source.
+func (c *PulseCapture) Add(event EdgeEvent) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.edges > 0 && event.Level != c.lastLevel {
+		length := event.Time - c.lastTime
+		if c.lastLevel == High {
+			c.high = length
+		} else {
+			c.low = length
+		}
+	}
+	c.lastLevel = event.Level
+	c.lastTime = event.Time
+	c.edges++
+}
+
+// Pulses returns length of last complete high and low pulse, zero if not measured yet
+func (c *PulseCapture) Pulses() (high, low time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.high, c.low
+}
+
+// Period returns period of the signal, zero if not measured yet
+func (c *PulseCapture) Period() time.Duration {
+	high, low := c.Pulses()
+	if high == 0 || low == 0 {
+		return 0
+	}
+	return high + low
+}
+
+// Frequency returns frequency [Hz] of the signal, zero if not measured yet
+func (c *PulseCapture) Frequency() float64 {
+	period := c.Period()
+	if period == 0 {
+		return 0
+	}
+	return float64(time.Second) / float64(period)
+}
+
+// DutyCycle returns ratio of high pulse to the period (0 - 1), zero if not measured yet
+func (c *PulseCapture) DutyCycle() float64 {
+	high, low := c.Pulses()
+	if high == 0 || low == 0 {
+		return 0
+	}
+	return float64(high) / float64(high+low)
+}
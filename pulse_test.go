@@ -0,0 +1,87 @@
+package rpio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestPulseCapture(t *testing.T) {
+	var c PulseCapture
+
+	if c.Period() != 0 || c.Frequency() != 0 || c.DutyCycle() != 0 {
+		t.Error("nothing should be measured without edges")
+	}
+
+	us := time.Microsecond
+	edges := []EdgeEvent{
+		{Level: High, Time: 100 * us},
+		{Level: Low, Time: 350 * us},
+		{Level: High, Time: 1100 * us},
+		{Level: Low, Time: 1350 * us},
+	}
+	for _, edge := range edges[:2] {
+		c.Add(edge)
+	}
+	if c.Period() != 0 {
+		t.Error("period should not be measured after single pulse")
+	}
+	for _, edge := range edges[2:] {
+		c.Add(edge)
+	}
+
+	if high, low := c.Pulses(); high != 250*us || low != 750*us {
+		t.Errorf("got pulses %s/%s, want 250µs/750µs", high, low)
+	}
+	if c.Period() != time.Millisecond {
+		t.Errorf("got period %s, want 1ms", c.Period())
+	}
+	if f := c.Frequency(); math.Abs(f-1000) > 1e-9 {
+		t.Errorf("got frequency %f, want 1000", f)
+	}
+	if d := c.DutyCycle(); math.Abs(d-0.25) > 1e-9 {
+		t.Errorf("got duty cycle %f, want 0.25", d)
+	}
+}
+
+// signalPin reads square wave with given high and low pulses, starting high at time 0
+type signalPin struct {
+	now       *time.Duration
+	high, low time.Duration
+}
+
+func (p *signalPin) Input()      {}
+func (p *signalPin) Output()     {}
+func (p *signalPin) Write(State) {}
+func (p *signalPin) Read() State {
+	if *p.now%(p.high+p.low) < p.high {
+		return High
+	}
+	return Low
+}
+
+func TestMeasurePulse(t *testing.T) {
+	us := time.Microsecond
+	now := 100 * us // in the middle of high pulse
+	pin := &signalPin{now: &now, high: 300 * us, low: 700 * us}
+	clock := func() time.Duration { now += us; return now }
+
+	if length, err := measurePulse(pin, clock, High, 10*time.Millisecond); err != nil || length != 300*us {
+		t.Errorf("high pulse %v %v, want 300µs", length, err)
+	}
+	if now < 1300*us {
+		t.Errorf("pulse in progress measured, ended at %v", now)
+	}
+	if length, err := measurePulse(pin, clock, Low, 10*time.Millisecond); err != nil || length != 700*us {
+		t.Errorf("low pulse %v %v, want 700µs", length, err)
+	}
+
+	pin.low = 0 // constantly high
+	start := now
+	if _, err := measurePulse(pin, clock, High, time.Millisecond); err != TimeoutError {
+		t.Errorf("error %v, want %v", err, TimeoutError)
+	}
+	if now-start > time.Millisecond+2*us {
+		t.Errorf("timeout after %v", now-start)
+	}
+}
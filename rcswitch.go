@@ -0,0 +1,360 @@
+package rpio
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"time"
+)
+
+// RFPulse is a high and a low pulse, lengths are in multiples of pulse length of the protocol
+type RFPulse struct {
+	High, Low int
+}
+
+// RFProtocol describes timing of OOK remote switch protocol (as in the RCSwitch library).
+// Each bit is sent as one pulse pair, followed by sync pulse pair after the whole code.
+type RFProtocol struct {
+	PulseLength int // [µs]
+	Sync        RFPulse
+	Zero        RFPulse
+	One         RFPulse
+	Inverted    bool // levels are swapped, pulse pairs start Low
+}
+
+// RFProtocols are protocols of RCSwitch, RFCode.Protocol 1 is RFProtocols[0].
+// Custom protocols can be appended.
+var RFProtocols = []RFProtocol{
+	{350, RFPulse{1, 31}, RFPulse{1, 3}, RFPulse{3, 1}, false}, // PT2262, EV1527 and most sockets
+	{650, RFPulse{1, 10}, RFPulse{1, 2}, RFPulse{2, 1}, false},
+	{100, RFPulse{30, 71}, RFPulse{4, 11}, RFPulse{9, 6}, false},
+	{380, RFPulse{1, 6}, RFPulse{1, 3}, RFPulse{3, 1}, false},
+	{500, RFPulse{6, 14}, RFPulse{1, 2}, RFPulse{2, 1}, false},
+	{450, RFPulse{23, 1}, RFPulse{1, 2}, RFPulse{2, 1}, true},  // HT6P20B
+	{150, RFPulse{2, 62}, RFPulse{1, 6}, RFPulse{6, 1}, false}, // HS2303-PT
+}
+
+var RFCodeError = errors.New("unknown RF protocol or code out of range")
+
+// RFCode is a code of remote switch or sensor
+type RFCode struct {
+	Protocol    int // index to RFProtocols + 1
+	Value       uint64
+	Bits        int           // length of the code, 1 - 64
+	PulseLength time.Duration // measured by receiver, when sending zero means default of the protocol
+	Time        time.Duration // start of the first received repetition
+}
+
+// Timing limits of received codes
+const (
+	rfSyncMin   = 2 * time.Millisecond  // lows longer than any bit are taken as sync
+	rfSyncMax   = 20 * time.Millisecond // longer lows are idle time between transmissions
+	rfTolerance = 60                    // [%] of pulse length
+	rfMinBits   = 8
+	rfMaxBits   = 64
+)
+
+func (c RFCode) protocol() (RFProtocol, time.Duration, error) {
+	if c.Protocol < 1 || c.Protocol > len(RFProtocols) || c.Bits < 1 || c.Bits > rfMaxBits ||
+		(c.Bits < 64 && c.Value >= 1<<uint(c.Bits)) {
+		return RFProtocol{}, 0, RFCodeError
+	}
+	p := RFProtocols[c.Protocol-1]
+	unit := c.PulseLength
+	if unit == 0 {
+		unit = micros(p.PulseLength)
+	}
+	return p, unit, nil
+}
+
+// RFTriState: Creates code from tri-state code word of PT2262 style switches,
+// each of '0', '1' and 'F' (floating) is sent as 2 bits 00, 11 and 01.
+func RFTriState(protocol int, tristate string) (RFCode, error) {
+	if len(tristate) == 0 || len(tristate) > rfMaxBits/2 {
+		return RFCode{}, RFCodeError
+	}
+	code := RFCode{Protocol: protocol, Bits: 2 * len(tristate)}
+	for _, c := range tristate {
+		code.Value <<= 2
+		switch c {
+		case '0':
+		case '1':
+			code.Value |= 3
+		case 'F', 'f':
+			code.Value |= 1
+		default:
+			return RFCode{}, RFCodeError
+		}
+	}
+	if _, _, err := code.protocol(); err != nil {
+		return RFCode{}, err
+	}
+	return code, nil
+}
+
+// TriState returns tri-state code word of the code, if it is one
+func (c RFCode) TriState() (string, bool) {
+	if c.Bits%2 != 0 {
+		return "", false
+	}
+	var b strings.Builder
+	for i := c.Bits - 2; i >= 0; i -= 2 {
+		switch c.Value >> uint(i) & 3 {
+		case 0:
+			b.WriteByte('0')
+		case 3:
+			b.WriteByte('1')
+		case 1:
+			b.WriteByte('F')
+		default:
+			return "", false
+		}
+	}
+	return b.String(), true
+}
+
+// EncodeRF: Encodes one transmission of the code to lengths of alternating high and low pulses,
+// starting with High (Low for inverted protocols). The code is sent from the most significant bit, followed by sync.
+func EncodeRF(code RFCode) ([]time.Duration, error) {
+	p, unit, err := code.protocol()
+	if err != nil {
+		return nil, err
+	}
+	var pulses []time.Duration
+	for i := code.Bits - 1; i >= 0; i-- {
+		bit := p.Zero
+		if code.Value>>uint(i)&1 != 0 {
+			bit = p.One
+		}
+		pulses = append(pulses, time.Duration(bit.High)*unit, time.Duration(bit.Low)*unit)
+	}
+	return append(pulses, time.Duration(p.Sync.High)*unit, time.Duration(p.Sync.Low)*unit), nil
+}
+
+// DecodeRF: Decodes code from pulses between two syncs, sync is the long low pulse ending them.
+// Pulses of normal protocols are the code and high pulse of the sync, pulses of inverted protocols
+// are the high pulse of the previous sync and the code.
+//
+// Some protocols match pulses of each other shifted by one (e.g. 1 and 6),
+// the protocol with the smallest timing error is detected.
+func DecodeRF(pulses []time.Duration, sync time.Duration) (RFCode, error) {
+	n := len(pulses)
+	if n%2 == 0 || n < 2*rfMinBits+1 || n > 2*rfMaxBits+1 {
+		return RFCode{}, RFCodeError
+	}
+	var best RFCode
+	var bestError float64
+	for i, p := range RFProtocols {
+		syncLow, syncHigh, syncPulse, data := p.Sync.Low, p.Sync.High, pulses[n-1], pulses[:n-1]
+		if p.Inverted {
+			syncLow, syncHigh, syncPulse, data = p.Sync.High, p.Sync.Low, pulses[0], pulses[1:]
+		}
+		unit := sync / time.Duration(syncLow)
+		total, ok := rfError(syncPulse, syncHigh, unit)
+		if !ok {
+			continue
+		}
+
+		code := RFCode{Protocol: i + 1, Bits: len(data) / 2, PulseLength: unit.Round(time.Microsecond)}
+		for j := 0; j < len(data) && ok; j += 2 {
+			zero, isZero := rfPairError(data[j], data[j+1], p.Zero, unit)
+			one, isOne := rfPairError(data[j], data[j+1], p.One, unit)
+			code.Value <<= 1
+			switch {
+			case isOne && (!isZero || one < zero):
+				code.Value |= 1
+				total += one
+			case isZero:
+				total += zero
+			default:
+				ok = false
+			}
+		}
+		if ok && (best.Bits == 0 || total < bestError) {
+			best, bestError = code, total
+		}
+	}
+	if best.Bits == 0 {
+		return RFCode{}, RFCodeError
+	}
+	return best, nil
+}
+
+// rfError returns difference of pulse from its nominal length relative to the nominal length,
+// and whether it is within tolerance
+func rfError(pulse time.Duration, units int, unit time.Duration) (float64, bool) {
+	nominal := time.Duration(units) * unit
+	diff := pulse - nominal
+	if diff < 0 {
+		diff = -diff
+	}
+	return float64(diff) / float64(nominal), diff <= unit*rfTolerance/100
+}
+
+func rfPairError(high, low time.Duration, bit RFPulse, unit time.Duration) (float64, bool) {
+	e1, ok1 := rfError(high, bit.High, unit)
+	e2, ok2 := rfError(low, bit.Low, unit)
+	return e1 + e2, ok1 && ok2
+}
+
+// RFDecoder decodes codes from edges of 433MHz receiver output.
+// Transmitters repeat each code several times, a code is emitted once it is received twice in a row,
+// further repetitions are ignored. The edges can come from any source, see RFReceiver.
+type RFDecoder struct {
+	events chan RFCode
+
+	mu     sync.Mutex
+	pulses []time.Duration
+	level  State
+	last   time.Duration // time of last edge
+	start  time.Duration // of pulses after last sync
+	code   RFCode        // last received
+	count  int           // repetitions of the code
+}
+
+// NewRFDecoder creates decoder, the receiver output is expected Low (idle)
+func NewRFDecoder() *RFDecoder {
+	return &RFDecoder{events: make(chan RFCode, 64), level: Low}
+}
+
+// Events returns channel of received codes, codes are dropped when it is full
+func (d *RFDecoder) Events() <-chan RFCode {
+	return d.events
+}
+
+// Add processes level change, events have to be added in order
+func (d *RFDecoder) Add(event EdgeEvent) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if event.Level == d.level {
+		return
+	}
+	length := event.Time - d.last
+	d.level, d.last = event.Level, event.Time
+
+	if event.Level == Low || length < rfSyncMin {
+		if len(d.pulses) > 2*rfMaxBits {
+			d.pulses, d.count = d.pulses[:0], 0 // noise
+		}
+		d.pulses = append(d.pulses, length)
+		return
+	}
+
+	// low sync ends
+	code, err := DecodeRF(d.pulses, length)
+	d.pulses = d.pulses[:0]
+	switch {
+	case err != nil || length > rfSyncMax:
+		d.count = 0
+	case d.count > 0 && code.Protocol == d.code.Protocol && code.Value == d.code.Value && code.Bits == d.code.Bits:
+		d.count++
+	default:
+		code.Time = d.start
+		d.code, d.count = code, 1
+	}
+	d.start = event.Time
+
+	if d.count == 2 {
+		select {
+		case d.events <- d.code:
+		default:
+		}
+	}
+}
+
+// RFReceiver decodes codes received on input pin, see RFDecoder.
+//
+// Edges are taken from kernel line events (/dev/gpiochipN) when available,
+// otherwise the pin is polled in a busy loop (see WatchEdges), which takes one CPU core.
+type RFReceiver struct {
+	*RFDecoder
+
+	file *os.File
+	stop chan struct{}
+	done chan struct{}
+}
+
+// NewRFReceiver starts receiving on input pin, call Close when done
+func NewRFReceiver(pin Pin) (*RFReceiver, error) {
+	r := &RFReceiver{RFDecoder: NewRFDecoder(), stop: make(chan struct{}), done: make(chan struct{})}
+
+	events, file, err := watchLineEdges(pin, "rpio-rf", r.stop)
+	if err != nil {
+		return nil, err
+	}
+	r.file = file
+
+	go func() {
+		defer close(r.done)
+		for event := range events {
+			r.Add(event)
+		}
+	}()
+	return r, nil
+}
+
+// Close stops receiving
+func (r *RFReceiver) Close() error {
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	if r.stop != nil {
+		close(r.stop)
+		<-r.done
+		r.stop = nil
+	}
+	return err
+}
+
+// RFTransmitter sends codes by 433MHz OOK transmitter module on output pin
+type RFTransmitter struct {
+	pin    DigitalPin
+	Repeat int // transmissions of each code, 10 by default
+
+	now   func() time.Duration
+	delay func(us uint32)
+	mu    sync.Mutex
+}
+
+// NewRFTransmitter sets pin to Output Low
+func NewRFTransmitter(pin DigitalPin) *RFTransmitter {
+	pin.Write(Low)
+	pin.Output()
+	return &RFTransmitter{pin: pin, Repeat: 10, now: systemTime, delay: DelayMicroseconds}
+}
+
+// Send transmits the code Repeat times, see EncodeRF
+func (t *RFTransmitter) Send(code RFCode) error {
+	pulses, err := EncodeRF(code)
+	if err != nil {
+		return err
+	}
+	first := High
+	if RFProtocols[code.Protocol-1].Inverted {
+		first = Low
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	runtime.LockOSThread()
+	defer runtime.UnlockOSThread()
+
+	next := t.now()
+	for r := 0; r < t.Repeat; r++ {
+		for i, pulse := range pulses {
+			t.pin.Write(first ^ State(i%2))
+			next += pulse // scheduled from start, so delays do not accumulate
+			if wait := next - t.now(); wait > 0 {
+				t.delay(uint32(wait / time.Microsecond))
+			}
+		}
+	}
+	t.pin.Write(Low)
+	return nil
+}
@@ -0,0 +1,157 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+// rfFrame converts one transmission to pulses between syncs and the sync as seen by receiver,
+// which stretches high pulses by 15% of pulse length
+func rfFrame(t *testing.T, code RFCode) ([]time.Duration, time.Duration) {
+	encoded, err := EncodeRF(code)
+	if err != nil {
+		t.Fatalf("%+v: %v", code, err)
+	}
+	p := RFProtocols[code.Protocol-1]
+	unit := micros(p.PulseLength)
+	if code.PulseLength != 0 {
+		unit = code.PulseLength
+	}
+	pulses := make([]time.Duration, len(encoded))
+	for i, pulse := range encoded {
+		if (i%2 == 0) != p.Inverted {
+			pulse += unit * 15 / 100
+		} else {
+			pulse -= unit * 15 / 100
+		}
+		pulses[i] = pulse
+	}
+	n := len(pulses)
+	if p.Inverted {
+		return append([]time.Duration{pulses[n-1]}, pulses[:n-2]...), pulses[n-2]
+	}
+	return pulses[:n-1], pulses[n-1]
+}
+
+func TestEncodeDecodeRF(t *testing.T) {
+	for protocol, p := range RFProtocols {
+		for _, code := range []RFCode{
+			{Value: 0x5a5a5a, Bits: 24},
+			{Value: 0xa1, Bits: 8},
+			{Value: 0x0fedcba987654321, Bits: 64},
+			{Value: 0x3ffffff, Bits: 26},
+			{Value: 0, Bits: 28},
+		} {
+			code.Protocol = protocol + 1
+			pulses, sync := rfFrame(t, code)
+			decoded, err := DecodeRF(pulses, sync)
+			code.PulseLength = micros(p.PulseLength)
+			if err != nil || decoded.Protocol != code.Protocol || decoded.Value != code.Value || decoded.Bits != code.Bits {
+				t.Errorf("%+v decoded as %+v %v", code, decoded, err)
+			}
+			if diff := decoded.PulseLength - code.PulseLength; diff < -code.PulseLength/10 || diff > code.PulseLength/10 {
+				t.Errorf("%+v: pulse length %v", code, decoded.PulseLength)
+			}
+		}
+	}
+
+	// remotes often differ from nominal pulse length
+	code := RFCode{Protocol: 1, Value: 0x1234, Bits: 16, PulseLength: 290 * time.Microsecond}
+	if decoded, err := DecodeRF(rfFrame(t, code)); err != nil || decoded.Protocol != 1 || decoded.Value != code.Value {
+		t.Errorf("%+v decoded as %+v %v", code, decoded, err)
+	}
+
+	pulses, sync := rfFrame(t, RFCode{Protocol: 1, Value: 0x5a5a5a, Bits: 24})
+	pulses[10] = 700 * time.Microsecond // between short and long
+	if _, err := DecodeRF(pulses, sync); err != RFCodeError {
+		t.Errorf("damaged frame: error %v", err)
+	}
+	if _, err := DecodeRF(pulses[:9], sync); err != RFCodeError {
+		t.Errorf("short frame: error %v", err)
+	}
+
+	for _, code := range []RFCode{
+		{Protocol: 0, Value: 1, Bits: 24},
+		{Protocol: len(RFProtocols) + 1, Value: 1, Bits: 24},
+		{Protocol: 1, Value: 0x100, Bits: 8},
+		{Protocol: 1, Bits: 0},
+		{Protocol: 1, Bits: 65},
+	} {
+		if _, err := EncodeRF(code); err != RFCodeError {
+			t.Errorf("%+v: error %v, want %v", code, err, RFCodeError)
+		}
+	}
+}
+
+func TestRFTriState(t *testing.T) {
+	code, err := RFTriState(1, "0FF0F0FFFF10")
+	if err != nil || code.Value != 0x14455c || code.Bits != 24 {
+		t.Fatalf("%+v %v", code, err)
+	}
+	if tristate, ok := code.TriState(); !ok || tristate != "0FF0F0FFFF10" {
+		t.Errorf("tri-state %q %v", tristate, ok)
+	}
+	if _, ok := (RFCode{Protocol: 1, Value: 0x800000, Bits: 24}).TriState(); ok {
+		t.Errorf("code with bits 10 is tri-state")
+	}
+	for _, tristate := range []string{"", "0FX0", "FFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFFF"} {
+		if _, err := RFTriState(1, tristate); err != RFCodeError {
+			t.Errorf("%q: error %v, want %v", tristate, err, RFCodeError)
+		}
+	}
+}
+
+// rfPin passes writes of transmitter as edges to decoder
+type rfPin struct {
+	decoder *RFDecoder
+	now     *time.Duration
+}
+
+func (p *rfPin) Input()      {}
+func (p *rfPin) Output()     {}
+func (p *rfPin) Read() State { return Low }
+func (p *rfPin) Write(level State) {
+	p.decoder.Add(EdgeEvent{Level: level, Time: *p.now})
+}
+
+func TestRFTransmitter(t *testing.T) {
+	d := NewRFDecoder()
+	now := time.Second
+	tx := NewRFTransmitter(&rfPin{decoder: d, now: &now})
+	tx.now = func() time.Duration { return now }
+	tx.delay = func(us uint32) { now += time.Duration(us) * time.Microsecond }
+
+	for protocol := range RFProtocols {
+		code := RFCode{Protocol: protocol + 1, Value: 0xc0ffee, Bits: 24}
+		for press := 0; press < 2; press++ {
+			start := now
+			if err := tx.Send(code); err != nil {
+				t.Fatal(err)
+			}
+			now += 100 * time.Millisecond // idle
+
+			select {
+			case received := <-d.Events():
+				if received.Protocol != code.Protocol || received.Value != code.Value || received.Bits != code.Bits {
+					t.Errorf("sent %+v, received %+v", code, received)
+				}
+				if !RFProtocols[protocol].Inverted && received.Time != start {
+					t.Errorf("protocol %d: received at %v, sent at %v", code.Protocol, received.Time, start)
+				}
+			default:
+				t.Fatalf("protocol %d: code not received", code.Protocol)
+			}
+			if len(d.Events()) != 0 {
+				t.Fatalf("protocol %d: repetitions received as codes", code.Protocol)
+			}
+		}
+	}
+
+	tx.Repeat = 1 // receiver needs code twice
+	tx.Send(RFCode{Protocol: 1, Value: 1, Bits: 24})
+	now += 100 * time.Millisecond
+	tx.Send(RFCode{Protocol: 1, Value: 2, Bits: 24})
+	if len(d.Events()) != 0 {
+		t.Errorf("single transmissions received")
+	}
+}
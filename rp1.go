@@ -0,0 +1,202 @@
+package rpio
+
+import (
+	"os"
+)
+
+// The Raspberry Pi 5 (BCM2712) has its GPIO in the RP1 southbridge, connected over PCIe.
+// The register layout is completely different from the BCM283x/2711 GPIO block:
+//   IO_BANK0   - per pin status and control (function select) registers
+//   SYS_RIO0   - registered IO, output/output enable/input level of pins in GPIO mode
+//   PADS_BANK0 - per pin pad control (pulls, input enable, drive strength)
+// See the RP1 peripherals datasheet for details:
+// https://datasheets.raspberrypi.com/rp1/rp1-peripherals.pdf
+
+// Memory offsets for RP1 gpio
+const (
+	rp1Base       = 0x1f00000000 // RP1 peripherals behind PCIe BAR, as seen from BCM2712
+	rp1GpioOffset = 0xd0000      // IO_BANK0, followed by SYS_RIO0 and PADS_BANK0
+
+	rp1IOBank = 0x00000 / 4
+	rp1RIO    = 0x10000 / 4
+	rp1Pads   = 0x20000 / 4
+
+	rp1MemLength = 0x30000
+
+	rp1PinCount = 28 // bank 0 - pins on the 40 pin header
+)
+
+// RP1 blocks have atomic register aliases
+const (
+	rp1Xor = 0x1000 / 4
+	rp1Set = 0x2000 / 4
+	rp1Clr = 0x3000 / 4
+)
+
+// SYS_RIO registers
+const (
+	rp1RIOOut = 0
+	rp1RIOOE  = 1
+	rp1RIOIn  = 2 // SYNC_IN
+)
+
+// RP1 function select values
+const (
+	rp1FuncAlt0 = 0
+	rp1FuncAlt3 = 3
+	rp1FuncRIO  = 5 // software controlled gpio
+)
+
+// PADS_BANK0 bits
+const (
+	rp1PadPullDown   = 1 << 2
+	rp1PadPullUp     = 1 << 3
+	rp1PadInput      = 1 << 6 // input enable
+	rp1PadOutDisable = 1 << 7
+)
+
+var (
+	rp1Mem  []uint32
+	rp1Mem8 []uint8
+)
+
+// isRP1 checks whether gpio is in RP1 (Raspberry Pi 5) instead of BCM283x/2711, known after Open
+func isRP1() bool {
+	return currentChip.SoC == BCM2712
+}
+
+// openRP1 maps RP1 gpio, tries /dev/gpiomem0 first (works without root), /dev/mem otherwise
+func openRP1() (err error) {
+	var file *os.File
+	var base int64
+
+	file, err = os.OpenFile("/dev/gpiomem0", os.O_RDWR|os.O_SYNC, os.ModePerm)
+	if os.IsNotExist(err) || os.IsPermission(err) {
+		file, err = os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, os.ModePerm)
+		base = rp1Base + rp1GpioOffset
+	}
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	memlock.Lock()
+	defer memlock.Unlock()
+
+	rp1Mem, rp1Mem8, err = memMap(file.Fd(), base, rp1MemLength)
+	return
+}
+
+func rp1Ctrl(pin Pin) int {
+	return rp1IOBank + int(pin)*2 + 1 // status, ctrl pair for every pin
+}
+
+func rp1Pad(pin Pin) int {
+	return rp1Pads + 1 + int(pin) // first register is voltage select
+}
+
+func rp1PinMode(pin Pin, mode Mode) {
+	f := uint32(rp1FuncRIO)
+
+	switch mode {
+	case Input, Output:
+		f = rp1FuncRIO
+	case Clock:
+		switch pin {
+		case 4, 5, 6: // gpclk0-2
+			f = rp1FuncAlt0
+		default:
+			return
+		}
+	case Pwm:
+		switch pin {
+		case 12, 13:
+			f = rp1FuncAlt0
+		case 18, 19:
+			f = rp1FuncAlt3
+		default:
+			return
+		}
+	case Spi:
+		switch pin {
+		case 7, 8, 9, 10, 11: // SPI0
+			f = rp1FuncAlt0
+		default:
+			return
+		}
+	case Alt0, Alt1, Alt2, Alt3, Alt4, Alt5:
+		f = uint32(mode - Alt0) // note that RP1 alt5 is the gpio function
+	}
+
+	memlock.Lock()
+	defer memlock.Unlock()
+
+	bit := uint32(1) << pin
+	if mode == Output {
+		rp1Mem[rp1RIO+rp1Set+rp1RIOOE] = bit
+	} else {
+		rp1Mem[rp1RIO+rp1Clr+rp1RIOOE] = bit
+	}
+
+	pad := rp1Pad(pin)
+	rp1Mem[pad] = rp1Mem[pad]&^rp1PadOutDisable | rp1PadInput
+
+	const funcMask = 0x1f
+	ctrl := rp1Ctrl(pin)
+	rp1Mem[ctrl] = rp1Mem[ctrl]&^funcMask | f
+}
+
+func rp1WritePin(pin Pin, state State) {
+	if state == Low {
+		rp1Mem[rp1RIO+rp1Clr+rp1RIOOut] = 1 << (pin & 31)
+	} else {
+		rp1Mem[rp1RIO+rp1Set+rp1RIOOut] = 1 << (pin & 31)
+	}
+}
+
+func rp1ReadPin(pin Pin) State {
+	if rp1Mem[rp1RIO+rp1RIOIn]&(1<<(pin&31)) != 0 {
+		return High
+	}
+	return Low
+}
+
+func rp1TogglePin(pin Pin) {
+	rp1Mem[rp1RIO+rp1Xor+rp1RIOOut] = 1 << (pin & 31)
+}
+
+func rp1PullMode(pin Pin, pull Pull) {
+	if int(pin) >= currentChip.PinCount {
+		return
+	}
+
+	memlock.Lock()
+	defer memlock.Unlock()
+
+	pad := rp1Pad(pin)
+	bits := rp1Mem[pad] &^ (rp1PadPullUp | rp1PadPullDown)
+	switch pull {
+	case PullUp:
+		bits |= rp1PadPullUp
+	case PullDown:
+		bits |= rp1PadPullDown
+	}
+	rp1Mem[pad] = bits
+}
+
+func rp1ReadPull(pin Pin) Pull {
+	if int(pin) >= currentChip.PinCount {
+		return PullNone
+	}
+
+	switch rp1Mem[rp1Pad(pin)] & (rp1PadPullUp | rp1PadPullDown) {
+	case 0:
+		return PullOff
+	case rp1PadPullUp:
+		return PullUp
+	case rp1PadPullDown:
+		return PullDown
+	default:
+		return PullNone // Invalid
+	}
+}
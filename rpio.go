@@ -65,6 +65,10 @@ and https://elinux.org/BCM2835_datasheet_errata - for errors in that spec
 
 Changes to support the BCM2711, used on the Raspberry Pi 4, were cribbed from https://github.com/RPi-Distro/raspi-gpio/
 
+On the Raspberry Pi 5 the GPIO is provided by the RP1 southbridge, which has a different register layout.
+Pin modes, read/write/toggle and pulls are supported there, but clock, pwm, spi, edge detection
+and interrupt functions are not (yet) and do nothing.
+
 */
 package rpio
 
@@ -76,7 +80,6 @@ import (
 	"reflect"
 	"sync"
 	"syscall"
-	"time"
 	"unsafe"
 )
 
@@ -94,6 +97,11 @@ const (
 	pwmOffset   = 0x20C000
 	spiOffset   = 0x204000
 	intrOffset  = 0x00B000
+	uartOffset  = 0x201000
+	auxOffset   = 0x215000
+	pcmOffset   = 0x203000
+	timerOffset = 0x003000
+	dmaOffset   = 0x007000
 
 	memLength = 4096
 )
@@ -107,24 +115,20 @@ const (
 )
 
 var (
-	gpioBase int64
-	clkBase  int64
-	pwmBase  int64
-	spiBase  int64
-	intrBase int64
+	gpioBase  int64
+	clkBase   int64
+	pwmBase   int64
+	spiBase   int64
+	intrBase  int64
+	uartBase  int64
+	auxBase   int64
+	pcmBase   int64
+	timerBase int64
+	dmaBase   int64
 
 	irqsBackup uint64
 )
 
-func init() {
-	base := getBase()
-	gpioBase = base + gpioOffset
-	clkBase = base + clkOffset
-	pwmBase = base + pwmOffset
-	spiBase = base + spiOffset
-	intrBase = base + intrOffset
-}
-
 // Pin mode, a pin can be set in Input or Output, Clock or Pwm mode
 const (
 	Input Mode = iota
@@ -170,19 +174,43 @@ const (
 
 // Arrays for 8 / 32 bit access to memory and a semaphore for write locking
 var (
-	memlock  sync.Mutex
-	gpioMem  []uint32
-	clkMem   []uint32
-	pwmMem   []uint32
-	spiMem   []uint32
-	intrMem  []uint32
-	gpioMem8 []uint8
-	clkMem8  []uint8
-	pwmMem8  []uint8
-	spiMem8  []uint8
-	intrMem8 []uint8
+	memlock   sync.Mutex
+	gpioMem   []uint32
+	clkMem    []uint32
+	pwmMem    []uint32
+	spiMem    []uint32
+	intrMem   []uint32
+	uartMem   []uint32
+	auxMem    []uint32
+	pcmMem    []uint32
+	timerMem  []uint32
+	dmaMem    []uint32
+	gpioMem8  []uint8
+	clkMem8   []uint8
+	pwmMem8   []uint8
+	spiMem8   []uint8
+	intrMem8  []uint8
+	uartMem8  []uint8
+	auxMem8   []uint8
+	pcmMem8   []uint8
+	timerMem8 []uint8
+	dmaMem8   []uint8
+
+	// devMem is set when peripherals are mapped from /dev/mem. /dev/gpiomem ignores the offset
+	// and maps GPIO registers to all the slices.
+	devMem bool
 )
 
+// DigitalPin is a pin which can be switched between Input and Output mode, read and written.
+// It is implemented by Pin, drivers of devices use it so they can work also with pins
+// of port expanders, or with simulated pins in tests.
+type DigitalPin interface {
+	Input()
+	Output()
+	Read() State
+	Write(state State)
+}
+
 // Input: Set pin as Input
 func (pin Pin) Input() {
 	PinMode(pin, Input)
@@ -270,8 +298,11 @@ func (pin Pin) PullOff() {
 }
 
 func (pin Pin) ReadPull() Pull {
-	if !isBCM2711() {
-		return PullNone // Can't read pull-up/pull-down state on other Pi boards
+	switch currentChip.Pull {
+	case PullPads:
+		return rp1ReadPull(pin)
+	case PullClocked:
+		return PullNone // Can't read pull-up/pull-down state on older Pi boards
 	}
 
 	reg := GPPUPPDN0 + (uint8(pin) >> 4)
@@ -305,6 +336,13 @@ func (pin Pin) EdgeDetected() bool {
 //
 // Spi mode should not be set by this directly, use SpiBegin instead.
 func PinMode(pin Pin, mode Mode) {
+	if int(pin) >= currentChip.PinCount {
+		return
+	}
+	if isRP1() {
+		rp1PinMode(pin, mode)
+		return
+	}
 
 	// Pin fsel register, 0 or 1 depending on bank
 	fselReg := uint8(pin) / 10
@@ -381,6 +419,11 @@ func PinMode(pin Pin, mode Mode) {
 // WritePin sets a given pin High or Low
 // by setting the clear or set registers respectively
 func WritePin(pin Pin, state State) {
+	if isRP1() {
+		rp1WritePin(pin, state)
+		return
+	}
+
 	p := uint8(pin)
 
 	// Set register, 7 / 8 depending on bank
@@ -400,6 +443,10 @@ func WritePin(pin Pin, state State) {
 
 // ReadPin reads the state of a pin
 func ReadPin(pin Pin) State {
+	if isRP1() {
+		return rp1ReadPin(pin)
+	}
+
 	// Input level register offset (13 / 14 depending on bank)
 	levelReg := uint8(pin)/32 + 13
 
@@ -412,6 +459,11 @@ func ReadPin(pin Pin) State {
 
 // TogglePin: Toggle a pin state (high -> low -> high)
 func TogglePin(pin Pin) {
+	if isRP1() {
+		rp1TogglePin(pin)
+		return
+	}
+
 	p := uint8(pin)
 
 	setReg := p/32 + 7
@@ -445,6 +497,10 @@ func TogglePin(pin Pin) {
 // WARNING: this might make your Pi unresponsive, if this happens, you should either run the code as root,
 // or add `dtoverlay=gpio-no-irq` to `/boot/config.txt` and restart your pi,
 func DetectEdge(pin Pin, edge Edge) {
+	if !currentChip.Has(PeriphEdgeDetect) {
+		return
+	}
+
 	if edge != NoEdge {
 		// disable GPIO event interruption to prevent freezing in some cases
 		DisableIRQs(1<<49 | 1<<52) // gpio_int[0] and gpio_int[3]
@@ -482,6 +538,10 @@ func DetectEdge(pin Pin, edge Edge) {
 //
 // Event detection has to be enabled first, by pin.Detect(edge)
 func EdgeDetected(pin Pin) bool {
+	if !currentChip.Has(PeriphEdgeDetect) {
+		return false
+	}
+
 	p := uint8(pin)
 
 	// Event detect status register (16/17)
@@ -493,11 +553,15 @@ func EdgeDetected(pin Pin) bool {
 }
 
 func PullMode(pin Pin, pull Pull) {
+	if isRP1() {
+		rp1PullMode(pin, pull)
+		return
+	}
 
 	memlock.Lock()
 	defer memlock.Unlock()
 
-	if isBCM2711() {
+	if currentChip.Pull == PullRegister {
 		pullreg := GPPUPPDN0 + (pin >> 4)
 		pullshift := (pin & 0xf) << 1
 
@@ -530,13 +594,13 @@ func PullMode(pin Pin, pull Pull) {
 			gpioMem[pullReg] &^= 3
 		}
 
-		// Wait for value to clock in, this is ugly, sorry :(
-		time.Sleep(time.Microsecond)
+		// Wait for value to clock in
+		DelayMicroseconds(1)
 
 		gpioMem[pullClkReg] = 1 << shift
 
 		// Wait for value to clock in
-		time.Sleep(time.Microsecond)
+		DelayMicroseconds(1)
 
 		gpioMem[pullReg] &^= 3
 		gpioMem[pullClkReg] = 0
@@ -557,18 +621,9 @@ func PullMode(pin Pin, pull Pull) {
 //   gp_clk2: pins 6 and 43
 //   pwm_clk: pins 12, 13, 18, 19, 40, 41, 45
 func SetFreq(pin Pin, freq int) {
-	// TODO: would be nice to choose best clock source depending on target frequency, oscilator is used for now
-	sourceFreq := 19200000 // oscilator frequency
-	if isBCM2711() {
-		sourceFreq = 52000000
+	if !currentChip.Has(PeriphClock) {
+		return
 	}
-	const divMask = 4095 // divi and divf have 12 bits each
-
-	divi := uint32(sourceFreq / freq)
-	divf := uint32(((sourceFreq % freq) << 12) / freq)
-
-	divi &= divMask
-	divf &= divMask
 
 	clkCtlReg := 28
 	clkDivReg := 28
@@ -591,6 +646,31 @@ func SetFreq(pin Pin, freq int) {
 		return
 	}
 
+	setClockFreq(clkCtlReg, clkDivReg, freq)
+}
+
+// setClockFreq sets frequency of clock manager clock with given control and divisor registers
+func setClockFreq(clkCtlReg, clkDivReg int, freq int) {
+	// TODO: would be nice to choose best clock source depending on target frequency, oscilator is used for now
+	setClockSource(clkCtlReg, clkDivReg, clkSrcOscillator, currentChip.OscillatorFreq, freq)
+}
+
+// Clock manager clock sources
+const (
+	clkSrcOscillator = 1
+	clkSrcPllD       = 6
+)
+
+// setClockSource sets clock manager clock to given frequency, derived from given source
+func setClockSource(clkCtlReg, clkDivReg int, src uint32, sourceFreq int, freq int) {
+	const divMask = 4095 // divi and divf have 12 bits each
+
+	divi := uint32(sourceFreq / freq)
+	divf := uint32(((sourceFreq % freq) << 12) / freq)
+
+	divi &= divMask
+	divf &= divMask
+
 	mash := uint32(1 << 9) // 1-stage MASH
 	if divi < 2 || divf == 0 {
 		mash = 0
@@ -602,18 +682,17 @@ func SetFreq(pin Pin, freq int) {
 	const PASSWORD = 0x5A000000
 	const busy = 1 << 7
 	const enab = 1 << 4
-	const src = 1 << 0 // oscilator
 
 	clkMem[clkCtlReg] = PASSWORD | (clkMem[clkCtlReg] &^ enab) // stop gpio clock (without changing src or mash)
 	for clkMem[clkCtlReg]&busy != 0 {
-		time.Sleep(time.Microsecond * 10)
+		DelayMicroseconds(10)
 	} // ... and wait for not busy
 
 	clkMem[clkCtlReg] = PASSWORD | mash | src          // set mash and source (without enabling clock)
 	clkMem[clkDivReg] = PASSWORD | (divi << 12) | divf // set dividers
 
 	// mash and src can not be changed in same step as enab, to prevent lock-up and glitches
-	time.Sleep(time.Microsecond * 10) // ... so wait for them to take effect
+	DelayMicroseconds(10) // ... so wait for them to take effect
 
 	clkMem[clkCtlReg] = PASSWORD | mash | src | enab // finally start clock
 
@@ -653,6 +732,10 @@ func SetDutyCycle(pin Pin, dutyLen, cycleLen uint32) {
 //
 // NOTE without root permission this function will simply do nothing successfully
 func SetDutyCycleWithPwmMode(pin Pin, dutyLen, cycleLen uint32, mode bool) {
+	if !currentChip.Has(PeriphPwm) {
+		return
+	}
+
 	const pwmCtlReg = 0
 	var (
 		pwmDatReg uint
@@ -694,11 +777,14 @@ func SetDutyCycleWithPwmMode(pin Pin, dutyLen, cycleLen uint32, mode bool) {
 	// set duty cycle
 	pwmMem[pwmDatReg] = dutyLen
 	pwmMem[pwmRngReg] = cycleLen
-	time.Sleep(time.Microsecond * 10)
+	DelayMicroseconds(10)
 }
 
 // StopPwm: Stop pwm for both channels
 func StopPwm() {
+	if !currentChip.Has(PeriphPwm) {
+		return
+	}
 	const pwmCtlReg = 0
 	const pwen = 1
 	pwmMem[pwmCtlReg] &^= pwen<<8 | pwen
@@ -706,6 +792,9 @@ func StopPwm() {
 
 // StartPwm starts pwm for both channels
 func StartPwm() {
+	if !currentChip.Has(PeriphPwm) {
+		return
+	}
 	const pwmCtlReg = 0
 	const pwen = 1
 	pwmMem[pwmCtlReg] |= pwen<<8 | pwen
@@ -715,6 +804,9 @@ func StartPwm() {
 // See 'ARM peripherals interrupts table' in pheripherals datasheet.
 // WARNING: you can corrupt your system, only use this if you know what you are doing.
 func EnableIRQs(irqs uint64) {
+	if !currentChip.Has(PeriphIrq) {
+		return
+	}
 	const irqEnable1 = 0x210 / 4
 	const irqEnable2 = 0x214 / 4
 	intrMem[irqEnable1] = uint32(irqs)       // IRQ 0..31
@@ -725,6 +817,9 @@ func EnableIRQs(irqs uint64) {
 // See 'ARM peripherals interrupts table' in pheripherals datasheet.
 // WARNING: you can corrupt your system, only use this if you know what you are doing.
 func DisableIRQs(irqs uint64) {
+	if !currentChip.Has(PeriphIrq) {
+		return
+	}
 	const irqDisable1 = 0x21C / 4
 	const irqDisable2 = 0x220 / 4
 	intrMem[irqDisable1] = uint32(irqs)       // IRQ 0..31
@@ -739,11 +834,35 @@ func backupIRQs() {
 
 // Open and memory map GPIO memory range from /dev/mem .
 // Some reflection magic is used to convert it to a unsafe []uint32 pointer
+//
+// The SoC is detected first, see CurrentChip.
+// On Raspberry Pi 5 the RP1 gpio is mapped from /dev/gpiomem0, or from /dev/mem as a fallback.
 func Open() (err error) {
+	currentChip, err = detectChip()
+	if err != nil {
+		return
+	}
+	if currentChip.SoC == BCM2712 {
+		return openRP1()
+	}
+
+	base := getBase()
+	gpioBase = base + gpioOffset
+	clkBase = base + clkOffset
+	pwmBase = base + pwmOffset
+	spiBase = base + spiOffset
+	intrBase = base + intrOffset
+	uartBase = base + uartOffset
+	auxBase = base + auxOffset
+	pcmBase = base + pcmOffset
+	timerBase = base + timerOffset
+	dmaBase = base + dmaOffset
+
 	var file *os.File
 
 	// Open fd for rw mem access; try dev/mem first (need root)
 	file, err = os.OpenFile("/dev/mem", os.O_RDWR|os.O_SYNC, os.ModePerm)
+	devMem = err == nil
 	if os.IsPermission(err) { // try gpiomem otherwise (some extra functions like clock and pwm setting wont work)
 		file, err = os.OpenFile("/dev/gpiomem", os.O_RDWR|os.O_SYNC, os.ModePerm)
 	}
@@ -757,45 +876,76 @@ func Open() (err error) {
 	defer memlock.Unlock()
 
 	// Memory map GPIO registers to slice
-	gpioMem, gpioMem8, err = memMap(file.Fd(), gpioBase)
+	gpioMem, gpioMem8, err = memMap(file.Fd(), gpioBase, memLength)
 	if err != nil {
 		return
 	}
 
 	// Memory map clock registers to slice
-	clkMem, clkMem8, err = memMap(file.Fd(), clkBase)
+	clkMem, clkMem8, err = memMap(file.Fd(), clkBase, memLength)
 	if err != nil {
 		return
 	}
 
 	// Memory map pwm registers to slice
-	pwmMem, pwmMem8, err = memMap(file.Fd(), pwmBase)
+	pwmMem, pwmMem8, err = memMap(file.Fd(), pwmBase, memLength)
 	if err != nil {
 		return
 	}
 
 	// Memory map spi registers to slice
-	spiMem, spiMem8, err = memMap(file.Fd(), spiBase)
+	spiMem, spiMem8, err = memMap(file.Fd(), spiBase, memLength)
 	if err != nil {
 		return
 	}
 
 	// Memory map interruption registers to slice
-	intrMem, intrMem8, err = memMap(file.Fd(), intrBase)
+	intrMem, intrMem8, err = memMap(file.Fd(), intrBase, memLength)
+	if err != nil {
+		return
+	}
+
+	// Memory map uart registers to slice
+	uartMem, uartMem8, err = memMap(file.Fd(), uartBase, memLength)
+	if err != nil {
+		return
+	}
+
+	// Memory map auxiliary peripherals (mini uart, spi1, spi2) registers to slice
+	auxMem, auxMem8, err = memMap(file.Fd(), auxBase, memLength)
+	if err != nil {
+		return
+	}
+
+	// Memory map pcm/i2s registers to slice
+	pcmMem, pcmMem8, err = memMap(file.Fd(), pcmBase, memLength)
+	if err != nil {
+		return
+	}
+
+	// Memory map system timer registers to slice
+	timerMem, timerMem8, err = memMap(file.Fd(), timerBase, memLength)
+	if err != nil {
+		return
+	}
+
+	// Memory map dma registers to slice
+	dmaMem, dmaMem8, err = memMap(file.Fd(), dmaBase, memLength)
 	if err != nil {
 		return
 	}
 
 	backupIRQs() // back up enabled IRQs, to restore it later
+	checkTimer() // timer is not accessible through /dev/gpiomem
 
 	return nil
 }
 
-func memMap(fd uintptr, base int64) (mem []uint32, mem8 []byte, err error) {
+func memMap(fd uintptr, base int64, length int) (mem []uint32, mem8 []byte, err error) {
 	mem8, err = syscall.Mmap(
 		int(fd),
 		base,
-		memLength,
+		length,
 		syscall.PROT_READ|syscall.PROT_WRITE,
 		syscall.MAP_SHARED,
 	)
@@ -812,11 +962,21 @@ func memMap(fd uintptr, base int64) (mem []uint32, mem8 []byte, err error) {
 
 // Close unmaps GPIO memory
 func Close() error {
+	if isRP1() {
+		memlock.Lock()
+		defer memlock.Unlock()
+		err := syscall.Munmap(rp1Mem8)
+		rp1Mem, rp1Mem8 = nil, nil
+		return err
+	}
+
 	EnableIRQs(irqsBackup) // Return IRQs to state where it was before - just to be nice
 
 	memlock.Lock()
 	defer memlock.Unlock()
-	for _, mem8 := range [][]uint8{gpioMem8, clkMem8, pwmMem8, spiMem8, intrMem8} {
+	devMem = false
+	timerValid = false // SystemTime falls back to monotonic clock, the timer is unmapped
+	for _, mem8 := range [][]uint8{gpioMem8, clkMem8, pwmMem8, spiMem8, intrMem8, uartMem8, auxMem8, pcmMem8, timerMem8, dmaMem8} {
 		if err := syscall.Munmap(mem8); err != nil {
 			return err
 		}
@@ -863,12 +1023,6 @@ func getBase() int64 {
 		return b
 	}
 
-	// Default to Pi 1
-	return int64(bcm2835Base)
-}
-
-// The Pi 4 uses a BCM 2711, which has different register offsets and base addresses than the rest of the Pi family (so far).  This
-// helper function checks if we're on a 2711 and hence a Pi 4
-func isBCM2711() bool {
-	return gpioMem[GPPUPPDN3] != 0x6770696f
+	// Default to base of detected chip (Pi 1 if not detected yet)
+	return currentChip.PeripheralBase
 }
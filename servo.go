@@ -0,0 +1,253 @@
+package rpio
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+var (
+	ServoPinError     = errors.New("pin has no PWM channel")
+	ServoChannelError = errors.New("PWM channel already used by another servo")
+	ServoConfigError  = errors.New("invalid servo pulse range")
+)
+
+const (
+	servoClockFreq = 1000000               // [Hz] pwm clock, 1µs resolution of pulses
+	servoCycle     = 20000                 // [clock ticks] 50Hz frame
+	servoFrame     = 20 * time.Millisecond // period of pulses
+)
+
+// servos shares the pwm clock and channels between servos
+var servos struct {
+	sync.Mutex
+	clock    bool      // pwm clock set to servoClockFreq, while any servo is open
+	channels [2]*Servo // servo using pwm channel
+}
+
+// ServoConfig: Calibration of a servo, zero values mean pulses 1000µs - 2000µs for 180°
+type ServoConfig struct {
+	MinPulse time.Duration // pulse at angle 0
+	MaxPulse time.Duration // pulse at angle Range
+	Range    float64       // [°] angle between MinPulse and MaxPulse
+	Inverted bool          // angle 0 at MaxPulse
+}
+
+// Servo controls hobby servo by 50Hz pulses of a hardware PWM channel.
+//
+// All servos run the pwm clock at 1MHz, so servos on both channels (eg. pins 18 and 19)
+// can be used at the same time. Changing the frequency of other Pwm pins (SetFreq)
+// or using PacerPwm for waveforms breaks timing of the servos.
+type Servo struct {
+	pin     Pin
+	channel int
+	config  ServoConfig
+	write   func(ticks uint32) // replaces pwm in tests
+	sleep   func(time.Duration)
+
+	mu    sync.Mutex
+	pulse time.Duration // current pulse, 0 when off
+	sweep int           // increased to cancel running Sweep
+}
+
+// pwmChannel returns pwm channel of pin, -1 if it has none
+func pwmChannel(pin Pin) int {
+	switch pin {
+	case 12, 18, 40:
+		return 0
+	case 13, 19, 41, 45:
+		return 1
+	}
+	return -1
+}
+
+// NewServo: Sets pin to Pwm mode and creates servo on its pwm channel.
+// No pulses are generated until the position is set.
+func NewServo(pin Pin, config ServoConfig) (*Servo, error) {
+	s, err := newServo(pin, config)
+	if err != nil {
+		return nil, err
+	}
+
+	servos.Lock()
+	defer servos.Unlock()
+	pin.Pwm()
+	if !servos.clock {
+		SetFreq(pin, servoClockFreq)
+		servos.clock = true
+	}
+	SetDutyCycle(pin, 0, servoCycle)
+	return s, nil
+}
+
+// newServo validates config and reserves pwm channel of pin
+func newServo(pin Pin, config ServoConfig) (*Servo, error) {
+	if config.MinPulse == 0 && config.MaxPulse == 0 {
+		config.MinPulse, config.MaxPulse = 1000*time.Microsecond, 2000*time.Microsecond
+	}
+	if config.Range == 0 {
+		config.Range = 180
+	}
+	if config.MinPulse <= 0 || config.MaxPulse <= config.MinPulse || config.MaxPulse >= servoFrame || config.Range < 0 {
+		return nil, ServoConfigError
+	}
+	channel := pwmChannel(pin)
+	if channel < 0 {
+		return nil, ServoPinError
+	}
+
+	servos.Lock()
+	defer servos.Unlock()
+	if servos.channels[channel] != nil {
+		return nil, ServoChannelError
+	}
+	s := &Servo{pin: pin, channel: channel, config: config, sleep: time.Sleep}
+	servos.channels[channel] = s
+	return s, nil
+}
+
+// SetPulse sets pulse length, limited to calibrated range. Stops running Sweep.
+func (s *Servo) SetPulse(pulse time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep++
+	s.output(pulse)
+}
+
+// Pulse returns current pulse length, 0 when off
+func (s *Servo) Pulse() time.Duration {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pulse
+}
+
+// SetAngle sets position [°] in range 0 - Range. Stops running Sweep.
+func (s *Servo) SetAngle(angle float64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep++
+	s.output(s.angleToPulse(angle))
+}
+
+// Angle returns current position [°], 0 when off
+func (s *Servo) Angle() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pulseToAngle(s.pulse)
+}
+
+// Sweep: Moves smoothly to angle [°] at speed [°/s], blocks until it gets there.
+//
+// The position is updated every frame (20ms). Servo that is off moves at its own speed.
+// Sweep is cancelled (returns early) by SetAngle, SetPulse, Off or another Sweep.
+func (s *Servo) Sweep(angle, speed float64) {
+	s.mu.Lock()
+	s.sweep++
+	sweep := s.sweep
+	target := s.pulseToAngle(s.angleToPulse(angle)) // limited to range
+	current := s.pulseToAngle(s.pulse)
+	if s.pulse == 0 || speed <= 0 {
+		s.output(s.angleToPulse(target))
+		s.mu.Unlock()
+		return
+	}
+	s.mu.Unlock()
+
+	step := speed * servoFrame.Seconds()
+	for current != target {
+		switch {
+		case target-current > step:
+			current += step
+		case current-target > step:
+			current -= step
+		default:
+			current = target
+		}
+
+		s.mu.Lock()
+		if s.sweep != sweep {
+			s.mu.Unlock()
+			return
+		}
+		s.output(s.angleToPulse(current))
+		s.mu.Unlock()
+
+		if current != target {
+			s.sleep(servoFrame)
+		}
+	}
+}
+
+// Off stops pulses, the servo does not hold its position. Stops running Sweep.
+func (s *Servo) Off() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sweep++
+	s.pulse = 0
+	s.writeTicks(0)
+}
+
+// Close stops pulses, sets the pin to Input mode and releases the pwm channel for other servos
+func (s *Servo) Close() {
+	s.Off()
+	if s.write == nil { // pwm is replaced in tests
+		s.pin.Input()
+	}
+
+	servos.Lock()
+	defer servos.Unlock()
+	if servos.channels[s.channel] == s {
+		servos.channels[s.channel] = nil
+	}
+	if servos.channels[0] == nil && servos.channels[1] == nil {
+		servos.clock = false // may be changed by SetFreq, set again by next servo
+	}
+}
+
+// output sets pulse limited to calibrated range
+func (s *Servo) output(pulse time.Duration) {
+	if pulse < s.config.MinPulse {
+		pulse = s.config.MinPulse
+	}
+	if pulse > s.config.MaxPulse {
+		pulse = s.config.MaxPulse
+	}
+	s.pulse = pulse
+	s.writeTicks(uint32(pulse.Round(time.Microsecond) / time.Microsecond))
+}
+
+func (s *Servo) writeTicks(ticks uint32) {
+	if s.write != nil {
+		s.write(ticks)
+		return
+	}
+	servos.Lock() // control register is shared by both channels
+	SetDutyCycle(s.pin, ticks, servoCycle)
+	servos.Unlock()
+}
+
+func (s *Servo) angleToPulse(angle float64) time.Duration {
+	if angle < 0 {
+		angle = 0
+	}
+	if angle > s.config.Range {
+		angle = s.config.Range
+	}
+	if s.config.Inverted {
+		angle = s.config.Range - angle
+	}
+	span := s.config.MaxPulse - s.config.MinPulse
+	return s.config.MinPulse + time.Duration(float64(span)*angle/s.config.Range)
+}
+
+func (s *Servo) pulseToAngle(pulse time.Duration) float64 {
+	if pulse == 0 {
+		return 0
+	}
+	span := s.config.MaxPulse - s.config.MinPulse
+	angle := float64(pulse-s.config.MinPulse) * s.config.Range / float64(span)
+	if s.config.Inverted {
+		angle = s.config.Range - angle
+	}
+	return angle
+}
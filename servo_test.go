@@ -0,0 +1,127 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestServoCalibration(t *testing.T) {
+	us := time.Microsecond
+	s, err := newServo(18, ServoConfig{MinPulse: 500 * us, MaxPulse: 2500 * us, Range: 270, Inverted: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var ticks []uint32
+	s.write = func(t uint32) { ticks = append(ticks, t) }
+
+	tests := []struct {
+		angle float64
+		pulse time.Duration
+		ticks uint32
+	}{
+		{0, 2500 * us, 2500},
+		{270, 500 * us, 500},
+		{135, 1500 * us, 1500},
+		{-10, 2500 * us, 2500}, // limited to range
+		{300, 500 * us, 500},
+	}
+	for _, test := range tests {
+		s.SetAngle(test.angle)
+		if s.Pulse() != test.pulse || ticks[len(ticks)-1] != test.ticks {
+			t.Errorf("angle %v: pulse %v (%d ticks), want %v", test.angle, s.Pulse(), ticks[len(ticks)-1], test.pulse)
+		}
+	}
+
+	s.SetPulse(2000 * us)
+	if a := s.Angle(); a != 67.5 {
+		t.Errorf("angle %v at 2000µs, want 67.5", a)
+	}
+	s.SetPulse(100 * us)
+	if s.Pulse() != 500*us {
+		t.Errorf("pulse %v, want 500µs", s.Pulse())
+	}
+	s.Off()
+	if s.Pulse() != 0 || ticks[len(ticks)-1] != 0 {
+		t.Errorf("pulse %v after Off", s.Pulse())
+	}
+}
+
+func TestServoChannels(t *testing.T) {
+	if _, err := newServo(17, ServoConfig{}); err != ServoPinError {
+		t.Errorf("pin 17: error %v, want %v", err, ServoPinError)
+	}
+	if _, err := newServo(18, ServoConfig{MinPulse: 2 * time.Millisecond, MaxPulse: time.Millisecond}); err != ServoConfigError {
+		t.Errorf("reversed pulses: error %v, want %v", err, ServoConfigError)
+	}
+
+	a, err := newServo(12, ServoConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a.write = func(uint32) {}
+	servos.clock = true // as set by NewServo
+
+	b, err := newServo(19, ServoConfig{}) // other channel
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.write = func(uint32) {}
+
+	if _, err := newServo(18, ServoConfig{}); err != ServoChannelError {
+		t.Errorf("pin 18 with 12 in use: error %v, want %v", err, ServoChannelError)
+	}
+	a.Close()
+	if !servos.clock {
+		t.Errorf("pwm clock released while servo on channel 1 is open")
+	}
+	c, err := newServo(18, ServoConfig{})
+	if err != nil {
+		t.Errorf("pin 18 after close: %v", err)
+	} else {
+		c.write = func(uint32) {}
+		c.Close()
+	}
+	b.Close()
+	if servos.clock {
+		t.Errorf("pwm clock kept after all servos closed")
+	}
+}
+
+func TestServoSweep(t *testing.T) {
+	s, err := newServo(13, ServoConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	var ticks []uint32
+	var slept time.Duration
+	s.write = func(t uint32) { ticks = append(ticks, t) }
+	s.sleep = func(d time.Duration) { slept += d }
+
+	s.Sweep(90, 0) // off, jumps
+	if len(ticks) != 1 || ticks[0] != 1500 {
+		t.Fatalf("ticks %v, want [1500]", ticks)
+	}
+
+	ticks = nil
+	s.Sweep(0, 100) // 2° per frame
+	if len(ticks) != 45 || ticks[0] != 1489 || ticks[44] != 1000 || s.Angle() != 0 {
+		t.Errorf("%d updates %v - %v, angle %v", len(ticks), ticks[0], ticks[len(ticks)-1], s.Angle())
+	}
+	if slept != 44*servoFrame {
+		t.Errorf("slept %v, want %v", slept, 44*servoFrame)
+	}
+
+	// cancelled by another command
+	ticks = nil
+	s.sleep = func(time.Duration) {
+		if len(ticks) == 3 {
+			s.SetAngle(45)
+		}
+	}
+	s.Sweep(180, 100)
+	if len(ticks) != 4 || ticks[3] != 1250 || s.Angle() != 45 {
+		t.Errorf("ticks %v, angle %v after cancel", ticks, s.Angle())
+	}
+}
@@ -0,0 +1,335 @@
+package rpio
+
+import (
+	"sync"
+)
+
+// BitOrder selects which bit of a byte is shifted first
+type BitOrder uint8
+
+const (
+	MSBFirst BitOrder = iota
+	LSBFirst
+)
+
+// ShiftOut: Shifts out byte on data pin, bit by bit (like shiftOut of Arduino).
+//
+// Each bit is set on data pin before the active edge (RiseEdge or FallEdge) of clock pin,
+// the clock is left at idle level, opposite to the edge. Both pins have to be outputs.
+func ShiftOut(data, clock DigitalPin, order BitOrder, edge Edge, value byte) {
+	active, idle := High, Low
+	if edge == FallEdge {
+		active, idle = Low, High
+	}
+	for i := uint(0); i < 8; i++ {
+		bit := value >> (7 - i) & 1
+		if order == LSBFirst {
+			bit = value >> i & 1
+		}
+		data.Write(State(bit))
+		clock.Write(active)
+		clock.Write(idle)
+	}
+}
+
+// ShiftIn: Shifts in byte from data pin, bit by bit (like shiftIn of Arduino).
+//
+// Each bit is read before the active edge (RiseEdge or FallEdge) of clock pin, which shifts out the next bit,
+// as 74HC165 and mode 0 SPI devices do. Clock pin has to be an output at idle level, opposite to the edge.
+func ShiftIn(data, clock DigitalPin, order BitOrder, edge Edge) byte {
+	active, idle := High, Low
+	if edge == FallEdge {
+		active, idle = Low, High
+	}
+	var value byte
+	for i := uint(0); i < 8; i++ {
+		if data.Read() == High {
+			if order == LSBFirst {
+				value |= 1 << i
+			} else {
+				value |= 0x80 >> i
+			}
+		}
+		clock.Write(active)
+		clock.Write(idle)
+	}
+	return value
+}
+
+// HC595 is a chain of 74HC595 shift registers, expanding outputs.
+// Device 0 is the first one in the chain (its SER is connected to the Pi), its outputs are pins 0 - 7 (QA - QH).
+//
+// Each output is a virtual pin, see Pin. Writes shift out the whole chain and latch it,
+// unless Buffered is set, then the outputs change at Flush.
+type HC595 struct {
+	Buffered bool
+
+	devices int
+	outputs []byte // outputs[i] bit j is output j of device i
+	shift   func(data []byte)
+	enable  DigitalPin // OE, active low
+	mu      sync.Mutex
+}
+
+// NewHC595 creates chain of given number of devices, bit-banged on data (SER), clock (SRCLK) and latch (RCLK) pins.
+// The pins are set to Output and all outputs to Low.
+func NewHC595(devices int, data, clock, latch DigitalPin) *HC595 {
+	for _, pin := range []DigitalPin{data, clock, latch} {
+		pin.Write(Low)
+		pin.Output()
+	}
+	return newHC595(devices, func(bytes []byte) {
+		for _, b := range bytes {
+			ShiftOut(data, clock, MSBFirst, RiseEdge, b)
+		}
+		latch.Write(High)
+		latch.Write(Low)
+	})
+}
+
+// NewHC595Spi creates chain of given number of devices connected to SPI0 (MOSI to SER, SCLK to SRCLK),
+// the chip select pin (CE0 - CE2) connected to RCLK latches the outputs at the end of transfer.
+//
+// Call SpiBegin(Spi0) first. Each write selects the chip, 1MHz speed and mode 0 of the SPI. All outputs are set to Low.
+func NewHC595Spi(devices int, chipSelect uint8) *HC595 {
+	return newHC595(devices, func(bytes []byte) {
+		SpiChipSelect(chipSelect)
+		SpiSpeed(1000000)
+		SpiMode(0, 0)
+		SpiTransmit(bytes...)
+	})
+}
+
+func newHC595(devices int, shift func(data []byte)) *HC595 {
+	c := &HC595{devices: devices, outputs: make([]byte, devices), shift: shift}
+	c.Flush()
+	return c
+}
+
+// AttachEnable sets pin connected to OE of the devices to Output, outputs are enabled (OE Low).
+// Pull OE up by a resistor to keep the outputs disabled until then.
+func (c *HC595) AttachEnable(enable DigitalPin) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.enable = enable
+	enable.Write(Low)
+	enable.Output()
+}
+
+// Enable enables outputs, or switches them to high impedance, if OE pin is attached
+func (c *HC595) Enable(on bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.enable == nil {
+		return
+	}
+	if on {
+		c.enable.Write(Low)
+	} else {
+		c.enable.Write(High)
+	}
+}
+
+// Pins returns number of outputs of the chain
+func (c *HC595) Pins() int {
+	return c.devices * 8
+}
+
+// Pin returns output n (device n/8, output n%8) as a virtual pin
+func (c *HC595) Pin(n int) *HC595Pin {
+	return &HC595Pin{chain: c, n: n}
+}
+
+// Write sets outputs of all devices, values[i] to device i
+func (c *HC595) Write(values []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	copy(c.outputs, values)
+	if !c.Buffered {
+		c.flush()
+	}
+}
+
+// Outputs returns copy of current (possibly not flushed) levels of outputs
+func (c *HC595) Outputs() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.outputs...)
+}
+
+// Flush shifts out and latches current outputs
+func (c *HC595) Flush() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.flush()
+}
+
+func (c *HC595) flush() {
+	// the last device is shifted first
+	data := make([]byte, c.devices)
+	for i, b := range c.outputs {
+		data[c.devices-1-i] = b
+	}
+	c.shift(data)
+}
+
+func (c *HC595) set(n int, state State) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 || n >= c.devices*8 {
+		return
+	}
+	if state == High {
+		c.outputs[n/8] |= 1 << uint(n%8)
+	} else {
+		c.outputs[n/8] &^= 1 << uint(n%8)
+	}
+	if !c.Buffered {
+		c.flush()
+	}
+}
+
+func (c *HC595) get(n int) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 || n >= c.devices*8 {
+		return Low
+	}
+	return State(c.outputs[n/8] >> uint(n%8) & 1)
+}
+
+// HC595Pin is an output of HC595 chain, implements DigitalPin
+type HC595Pin struct {
+	chain *HC595
+	n     int
+}
+
+// Input does nothing, the pin is always an output
+func (p *HC595Pin) Input() {}
+
+// Output does nothing, the pin is always an output
+func (p *HC595Pin) Output() {}
+
+// Write sets level of the output
+func (p *HC595Pin) Write(state State) {
+	p.chain.set(p.n, state)
+}
+
+// Read returns level the output is set to
+func (p *HC595Pin) Read() State {
+	return p.chain.get(p.n)
+}
+
+// HC165 is a chain of 74HC165 shift registers, expanding inputs.
+// Device 0 is the one connected to the Pi (by its QH output), its inputs are pins 0 - 7 (A - H).
+//
+// Each input is a virtual pin, see Pin. Reads of pins load and shift in the whole chain,
+// unless Buffered is set, then they return levels loaded by last Update.
+type HC165 struct {
+	Buffered bool
+
+	devices int
+	inputs  []byte // inputs[i] bit j is input j of device i
+	load    DigitalPin
+	shift   func(data []byte)
+	mu      sync.Mutex
+}
+
+// NewHC165 creates chain of given number of devices, bit-banged on data (QH), clock (CLK) and load (SH/LD) pins.
+// Data pin is set to Input, the others to Output.
+func NewHC165(devices int, data, clock, load DigitalPin) *HC165 {
+	data.Input()
+	clock.Write(Low)
+	clock.Output()
+	return newHC165(devices, load, func(bytes []byte) {
+		for i := range bytes {
+			bytes[i] = ShiftIn(data, clock, MSBFirst, RiseEdge)
+		}
+	})
+}
+
+// NewHC165Spi creates chain of given number of devices connected to SPI0 (MISO to QH, SCLK to CLK),
+// chip select (CE0 - CE2) has to be connected to CLK INH, so changes of SPI mode do not shift the registers.
+// The load pin (SH/LD) is driven separately.
+//
+// Call SpiBegin(Spi0) first. Each read selects the chip, 1MHz speed and mode 2 of the SPI
+// (the bits are sampled on falling edge, before the rising edge shifts out the next one).
+func NewHC165Spi(devices int, chipSelect uint8, load DigitalPin) *HC165 {
+	return newHC165(devices, load, func(bytes []byte) {
+		SpiChipSelect(chipSelect)
+		SpiSpeed(1000000)
+		SpiMode(1, 0)
+		SpiExchange(bytes)
+	})
+}
+
+func newHC165(devices int, load DigitalPin, shift func(data []byte)) *HC165 {
+	load.Write(High)
+	load.Output()
+	return &HC165{devices: devices, inputs: make([]byte, devices), load: load, shift: shift}
+}
+
+// Pins returns number of inputs of the chain
+func (c *HC165) Pins() int {
+	return c.devices * 8
+}
+
+// Pin returns input n (device n/8, input n%8) as a virtual pin
+func (c *HC165) Pin(n int) *HC165Pin {
+	return &HC165Pin{chain: c, n: n}
+}
+
+// Update loads levels of all inputs and returns them, inputs[i] of device i
+func (c *HC165) Update() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.update()
+	return append([]byte(nil), c.inputs...)
+}
+
+func (c *HC165) update() {
+	c.load.Write(Low) // parallel load
+	c.load.Write(High)
+	data := make([]byte, c.devices) // zeros for SPI
+	c.shift(data)
+	copy(c.inputs, data)
+}
+
+// Inputs returns levels of all inputs loaded by last Update
+func (c *HC165) Inputs() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.inputs...)
+}
+
+func (c *HC165) get(n int) State {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if n < 0 || n >= c.devices*8 {
+		return Low
+	}
+	if !c.Buffered {
+		c.update()
+	}
+	return State(c.inputs[n/8] >> uint(n%8) & 1)
+}
+
+// HC165Pin is an input of HC165 chain, implements DigitalPin
+type HC165Pin struct {
+	chain *HC165
+	n     int
+}
+
+// Input does nothing, the pin is always an input
+func (p *HC165Pin) Input() {}
+
+// Output does nothing, the pin is always an input
+func (p *HC165Pin) Output() {}
+
+// Write does nothing, the pin is always an input
+func (p *HC165Pin) Write(state State) {}
+
+// Read returns level of the input
+func (p *HC165Pin) Read() State {
+	return p.chain.get(p.n)
+}
@@ -0,0 +1,180 @@
+package rpio
+
+import (
+	"bytes"
+	"testing"
+)
+
+// shiftSim simulates chain of 74HC595 (shifting on rising edge of clock, latching on rising edge of latch)
+// or 74HC165 (loading while load is Low, shifting on rising edge of clock)
+type shiftSim struct {
+	register []byte // register[0] is device 0
+	latched  []byte // 595 outputs
+	inputs   []byte // 165 parallel inputs
+	ser      State
+	clock    State
+	latch    State
+	load     State
+	shifts   int
+	in       bool // 165 chain, shifting towards device 0
+}
+
+// shift moves all bits by one, bit 7 (QH) goes to bit 0 of the next device in the chain
+func (s *shiftSim) shift() {
+	if s.in {
+		for i := range s.register {
+			var carry byte
+			if i+1 < len(s.register) {
+				carry = s.register[i+1] >> 7
+			}
+			s.register[i] = s.register[i]<<1 | carry
+		}
+		s.shifts++
+		return
+	}
+	carry := byte(s.ser)
+	for i := range s.register {
+		next := s.register[i] >> 7
+		s.register[i] = s.register[i]<<1 | carry
+		carry = next
+	}
+	s.shifts++
+}
+
+// shiftSimPin is a signal of the simulated chain: "ser", "clock", "latch", "load" or "out" (QH of device 0)
+type shiftSimPin struct {
+	sim  *shiftSim
+	name string
+}
+
+func (p *shiftSimPin) Input()  {}
+func (p *shiftSimPin) Output() {}
+
+func (p *shiftSimPin) Write(state State) {
+	s := p.sim
+	switch p.name {
+	case "ser":
+		s.ser = state
+	case "clock":
+		if s.clock == Low && state == High && s.load == High {
+			s.shift()
+		}
+		s.clock = state
+	case "latch":
+		if s.latch == Low && state == High {
+			s.latched = append([]byte(nil), s.register...)
+		}
+		s.latch = state
+	case "load":
+		if state == Low {
+			copy(s.register, s.inputs)
+		}
+		s.load = state
+	}
+}
+
+func (p *shiftSimPin) Read() State {
+	s := p.sim
+	if p.name == "out" {
+		return State(s.register[0] >> 7)
+	}
+	return Low
+}
+
+func TestShiftOutIn(t *testing.T) {
+	sim := &shiftSim{register: make([]byte, 1), load: High}
+	data, clock := &shiftSimPin{sim, "ser"}, &shiftSimPin{sim, "clock"}
+
+	ShiftOut(data, clock, MSBFirst, RiseEdge, 0xa1)
+	if sim.register[0] != 0xa1 || sim.clock != Low {
+		t.Errorf("MSB first: register %#x, clock %v", sim.register[0], sim.clock)
+	}
+	ShiftOut(data, clock, LSBFirst, RiseEdge, 0xa1)
+	if sim.register[0] != 0x85 {
+		t.Errorf("LSB first: register %#x", sim.register[0])
+	}
+
+	out := &shiftSimPin{sim, "out"}
+	sim.register[0] = 0xc3
+	if v := ShiftIn(out, clock, MSBFirst, RiseEdge); v != 0xc3 {
+		t.Errorf("MSB first: read %#x", v)
+	}
+	sim.register[0] = 0xc1
+	if v := ShiftIn(out, clock, LSBFirst, RiseEdge); v != 0x83 {
+		t.Errorf("LSB first: read %#x", v)
+	}
+
+	sim.clock = High
+	ShiftOut(data, clock, MSBFirst, FallEdge, 0x0f) // each bit is set before the falling edge
+	if sim.register[0] != 0x0f || sim.shifts != 40 || sim.clock != High {
+		t.Errorf("%d shifts, clock %v", sim.shifts, sim.clock)
+	}
+}
+
+func TestHC595(t *testing.T) {
+	sim := &shiftSim{register: make([]byte, 3), load: High}
+	sim.latched = []byte{0xff, 0xff, 0xff}
+	chain := NewHC595(3, &shiftSimPin{sim, "ser"}, &shiftSimPin{sim, "clock"}, &shiftSimPin{sim, "latch"})
+	if !bytes.Equal(sim.latched, []byte{0, 0, 0}) {
+		t.Fatalf("outputs % x after init", sim.latched)
+	}
+
+	var pin DigitalPin = chain.Pin(9) // device 1, QB
+	pin.Write(High)
+	if !bytes.Equal(sim.latched, []byte{0, 0x02, 0}) || pin.Read() != High {
+		t.Errorf("outputs % x after pin 9 High", sim.latched)
+	}
+
+	chain.Buffered = true
+	chain.Pin(0).Write(High)
+	chain.Pin(23).Write(High)
+	pin.Write(Low)
+	if !bytes.Equal(sim.latched, []byte{0, 0x02, 0}) {
+		t.Errorf("buffered writes shifted out: % x", sim.latched)
+	}
+	chain.Flush()
+	if !bytes.Equal(sim.latched, []byte{0x01, 0, 0x80}) {
+		t.Errorf("outputs % x after flush", sim.latched)
+	}
+
+	chain.Buffered = false
+	chain.Write([]byte{0x12, 0x34, 0x56})
+	if !bytes.Equal(sim.latched, []byte{0x12, 0x34, 0x56}) || !bytes.Equal(chain.Outputs(), sim.latched) {
+		t.Errorf("outputs % x after write", sim.latched)
+	}
+
+	oe := &stepperPin{level: High}
+	chain.AttachEnable(oe)
+	chain.Enable(false)
+	if oe.level != High || !oe.output {
+		t.Errorf("OE %v when disabled", oe.level)
+	}
+	chain.Enable(true)
+	if oe.level != Low {
+		t.Errorf("OE %v when enabled", oe.level)
+	}
+}
+
+func TestHC165(t *testing.T) {
+	sim := &shiftSim{register: make([]byte, 2), inputs: []byte{0x81, 0x3c}, load: High, in: true}
+	chain := NewHC165(2, &shiftSimPin{sim, "out"}, &shiftSimPin{sim, "clock"}, &shiftSimPin{sim, "load"})
+
+	if inputs := chain.Update(); !bytes.Equal(inputs, sim.inputs) {
+		t.Errorf("inputs % x, want % x", inputs, sim.inputs)
+	}
+
+	var pin DigitalPin = chain.Pin(10) // device 1, input C
+	if pin.Read() != High || chain.Pin(8).Read() != Low || chain.Pin(7).Read() != High {
+		t.Errorf("pins 10, 8, 7: %v %v %v", pin.Read(), chain.Pin(8).Read(), chain.Pin(7).Read())
+	}
+
+	sim.inputs[1] = 0
+	chain.Buffered = true
+	if pin.Read() != High {
+		t.Errorf("buffered read updated the inputs")
+	}
+	chain.Update()
+	if pin.Read() != Low || !bytes.Equal(chain.Inputs(), []byte{0x81, 0}) {
+		t.Errorf("inputs % x after update", chain.Inputs())
+	}
+}
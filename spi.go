@@ -21,7 +21,8 @@ const (
 )
 
 var (
-	SpiMapError = errors.New("SPI registers not mapped correctly - are you root?")
+	SpiMapError         = errors.New("SPI registers not mapped correctly - are you root?")
+	SpiUnsupportedError = errors.New("SPI is not supported on this chip")
 )
 
 // SpiBegin: Sets all pins of given SPI device to SPI mode
@@ -34,6 +35,10 @@ var (
 //
 // Note that you should disable SPI interface in raspi-config first!
 func SpiBegin(dev SpiDev) error {
+	if !currentChip.Has(PeriphSpi) {
+		return SpiUnsupportedError
+	}
+
 	spiMem[csReg] = 0 // reset spi settings to default
 	if spiMem[csReg] == 0 {
 		// this should not read only zeroes after reset -> mem map failed
@@ -61,10 +66,7 @@ func SpiEnd(dev SpiDev) {
 // Param speed may be as big as 125MHz in theory, but
 // only values up to 31.25MHz are considered relayable.
 func SpiSpeed(speed int) {
-	coreFreq := 250 * 1000000
-	if isBCM2711() {
-		coreFreq = 550 * 1000000
-	}
+	coreFreq := currentChip.CoreFreq
 	cdiv := uint32(coreFreq / speed)
 	setSpiDiv(cdiv)
 }
@@ -0,0 +1,402 @@
+package rpio
+
+import (
+	"errors"
+	"math"
+	"sync"
+	"time"
+)
+
+// ProfileShape selects shape of acceleration ramps
+type ProfileShape uint8
+
+const (
+	ProfileTrapezoidal ProfileShape = iota // constant acceleration
+	ProfileSCurve                          // acceleration rises and falls smoothly, peak is the configured one
+)
+
+var (
+	StepperMicrostepError = errors.New("unsupported microstep division")
+	StepperConfigError    = errors.New("stepper max speed has to be positive and acceleration non-negative")
+)
+
+// StepProfile generates intervals between steps of a move with acceleration ramps,
+// independently of any output. The move can be retargeted at any time,
+// the motor then decelerates (past the target if necessary) and returns.
+type StepProfile struct {
+	maxSpeed float64 // [steps/s]
+	shape    ProfileShape
+	ramp     float64 // length of ramp [steps]
+
+	position int64
+	target   int64
+	dir      int64 // direction of movement, 0 when stopped
+	x        int   // position on the ramp, 0 = stopped, ramp = full speed
+}
+
+// NewStepProfile creates profile with given maximal speed [steps/s] and acceleration [steps/s²],
+// see SetSpeed
+func NewStepProfile(maxSpeed, acceleration float64, shape ProfileShape) (*StepProfile, error) {
+	p := &StepProfile{shape: shape}
+	if err := p.SetSpeed(maxSpeed, acceleration); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+// SetSpeed changes maximal speed [steps/s] and acceleration [steps/s²].
+// Acceleration 0 means no ramps, the motor starts and stops at full speed.
+func (p *StepProfile) SetSpeed(maxSpeed, acceleration float64) error {
+	if !(maxSpeed > 0) || !(acceleration >= 0) || math.IsInf(maxSpeed, 0) || math.IsInf(acceleration, 0) {
+		return StepperConfigError
+	}
+	p.maxSpeed = maxSpeed
+	p.ramp = 0
+	if acceleration > 0 {
+		// v² = 2 a x for constant acceleration, S-curve reaches 1.5 times the average slope of v²
+		p.ramp = maxSpeed * maxSpeed / (2 * acceleration)
+		if p.shape == ProfileSCurve {
+			p.ramp *= 1.5
+		}
+	}
+	if p.x > int(math.Ceil(p.ramp)) {
+		p.x = int(math.Ceil(p.ramp))
+	}
+	return nil
+}
+
+// SetTarget sets target position of the move
+func (p *StepProfile) SetTarget(target int64) {
+	p.target = target
+}
+
+// Target returns target position
+func (p *StepProfile) Target() int64 {
+	return p.target
+}
+
+// SetPosition sets current position (and target), the motor has to be stopped
+func (p *StepProfile) SetPosition(position int64) {
+	p.position, p.target = position, position
+	p.dir, p.x = 0, 0
+}
+
+// Position returns current position [steps]
+func (p *StepProfile) Position() int64 {
+	return p.position
+}
+
+// Stop sets target to the nearest position the motor can decelerate to
+func (p *StepProfile) Stop() {
+	p.target = p.position + p.dir*int64(p.x)
+}
+
+// Moving checks whether the motor moves or has not reached target
+func (p *StepProfile) Moving() bool {
+	return p.x > 0 || p.position != p.target
+}
+
+// Speed returns current speed [steps/s], negative in backward direction
+func (p *StepProfile) Speed() float64 {
+	return float64(p.dir) * p.speed(p.x)
+}
+
+// speed returns speed at ramp position x
+func (p *StepProfile) speed(x int) float64 {
+	if x <= 0 {
+		return 0
+	}
+	u := float64(x) / p.ramp
+	if u >= 1 {
+		return p.maxSpeed
+	}
+	if p.shape == ProfileSCurve {
+		u = u * u * (3 - 2*u) // smoothstep of v², so acceleration starts and ends at zero
+	}
+	return p.maxSpeed * math.Sqrt(u)
+}
+
+// Next advances to the next step, returns its direction (+1 or -1) and interval to the following step.
+// Returns ok false when the target is reached and the motor stopped.
+func (p *StepProfile) Next() (dir int, interval time.Duration, ok bool) {
+	if p.x == 0 {
+		if p.position == p.target {
+			p.dir = 0
+			return 0, 0, false
+		}
+		p.dir = 1
+		if p.target < p.position {
+			p.dir = -1
+		}
+	}
+
+	remaining := (p.target - p.position) * p.dir // steps left in current direction
+	if remaining < 0 {
+		remaining = 0
+	}
+
+	var v float64
+	switch {
+	case remaining > int64(p.x)+1 && float64(p.x) < p.ramp: // accelerate
+		p.x++
+		v = p.speed(p.x)
+	case remaining > int64(p.x): // cruise
+		v = p.speed(p.x)
+	default: // decelerate, possibly past the target
+		v = p.speed(p.x)
+		p.x--
+	}
+	if p.x == 0 && v == 0 { // single step from standstill
+		v = p.speed(1)
+	}
+
+	p.position += p.dir
+	return int(p.dir), time.Duration(float64(time.Second) / v), true
+}
+
+// StepperConfig: Settings of Stepper motion and optional pins
+type StepperConfig struct {
+	MaxSpeed     float64 // [steps/s], required
+	Acceleration float64 // [steps/s²], 0 means no ramps
+	Profile      ProfileShape
+
+	Enable       DigitalPin   // optional enable pin of the driver
+	EnableActive State        // level of Enable pin enabling the driver, Low for A4988/DRV8825
+	Microstep    []DigitalPin // optional mode pins, MS1 - MS3 (A4988) or M0 - M2 (DRV8825)
+}
+
+// Stepper drives stepper motor through step/dir driver or directly by 4 coil pins.
+//
+// Call Run repeatedly (it never blocks), or RunToPosition, to move the motor towards its target.
+type Stepper struct {
+	config  StepperConfig
+	profile *StepProfile
+
+	step, dir DigitalPin   // step/dir driver
+	lastDir   int          // direction set on dir pin, 0 if not set yet
+	coils     []DigitalPin // unipolar motor
+	sequence  []uint8      // coil patterns
+	phase     int
+
+	mu       sync.Mutex
+	nextTime time.Duration // of next step, 0 if not scheduled
+	now      func() time.Duration
+	delay    func(us uint32)
+}
+
+// Coil patterns of unipolar motors, bit i drives coil pin i
+var (
+	fullStepSequence = []uint8{0b0011, 0b0110, 0b1100, 0b1001}
+	halfStepSequence = []uint8{0b0001, 0b0011, 0b0010, 0b0110, 0b0100, 0b1100, 0b1000, 0b1001}
+)
+
+// NewStepDirStepper creates stepper driven by step/dir driver (A4988, DRV8825), sets its pins to Output
+func NewStepDirStepper(step, dir DigitalPin, config StepperConfig) (*Stepper, error) {
+	s, err := newStepper(config)
+	if err != nil {
+		return nil, err
+	}
+	s.step, s.dir = step, dir
+	for _, pin := range append([]DigitalPin{step, dir}, config.Microstep...) {
+		pin.Write(Low)
+		pin.Output()
+	}
+	s.initEnable()
+	return s, nil
+}
+
+// NewUnipolarStepper creates stepper with coils driven directly (eg. 28BYJ-48 with ULN2003),
+// pins in order of coils A, B, C, D. Half stepping doubles the resolution.
+func NewUnipolarStepper(coils [4]DigitalPin, halfStep bool, config StepperConfig) (*Stepper, error) {
+	s, err := newStepper(config)
+	if err != nil {
+		return nil, err
+	}
+	s.coils = coils[:]
+	s.sequence = fullStepSequence
+	if halfStep {
+		s.sequence = halfStepSequence
+	}
+	for _, pin := range s.coils {
+		pin.Write(Low)
+		pin.Output()
+	}
+	s.initEnable()
+	return s, nil
+}
+
+func newStepper(config StepperConfig) (*Stepper, error) {
+	profile, err := NewStepProfile(config.MaxSpeed, config.Acceleration, config.Profile)
+	if err != nil {
+		return nil, err
+	}
+	return &Stepper{config: config, profile: profile, now: systemTime, delay: DelayMicroseconds}, nil
+}
+
+func (s *Stepper) initEnable() {
+	if s.config.Enable != nil {
+		s.config.Enable.Write(s.config.EnableActive ^ 1)
+		s.config.Enable.Output()
+	}
+}
+
+// Enable enables (or disables) the driver by Enable pin, or powers off coils of unipolar motor
+func (s *Stepper) Enable(enable bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.config.Enable != nil {
+		level := s.config.EnableActive
+		if !enable {
+			level ^= 1
+		}
+		s.config.Enable.Write(level)
+	}
+	if s.coils != nil {
+		pattern := s.sequence[s.phase]
+		if !enable {
+			pattern = 0
+		}
+		s.writeCoils(pattern)
+	}
+}
+
+// SetMicrostep sets levels of Microstep pins, bit i to pin i (see A4988Microstep, DRV8825Microstep)
+func (s *Stepper) SetMicrostep(bits uint8) {
+	for i, pin := range s.config.Microstep {
+		pin.Write(State(bits >> uint(i) & 1))
+	}
+}
+
+// A4988Microstep returns levels of MS1 - MS3 pins for step division 1, 2, 4, 8 or 16
+func A4988Microstep(division int) (uint8, error) {
+	bits, ok := map[int]uint8{1: 0b000, 2: 0b001, 4: 0b010, 8: 0b011, 16: 0b111}[division]
+	if !ok {
+		return 0, StepperMicrostepError
+	}
+	return bits, nil
+}
+
+// DRV8825Microstep returns levels of M0 - M2 pins for step division 1 - 32
+func DRV8825Microstep(division int) (uint8, error) {
+	bits, ok := map[int]uint8{1: 0b000, 2: 0b001, 4: 0b010, 8: 0b011, 16: 0b100, 32: 0b101}[division]
+	if !ok {
+		return 0, StepperMicrostepError
+	}
+	return bits, nil
+}
+
+// SetSpeed changes maximal speed [steps/s] and acceleration [steps/s²], see StepProfile.SetSpeed
+func (s *Stepper) SetSpeed(maxSpeed, acceleration float64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile.SetSpeed(maxSpeed, acceleration)
+}
+
+// MoveTo sets absolute target position
+func (s *Stepper) MoveTo(position int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile.SetTarget(position)
+}
+
+// Move sets target position relative to current target
+func (s *Stepper) Move(steps int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile.SetTarget(s.profile.Target() + steps)
+}
+
+// Stop decelerates the motor to stop as soon as possible
+func (s *Stepper) Stop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile.Stop()
+}
+
+// Position returns current position [steps]
+func (s *Stepper) Position() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile.Position()
+}
+
+// SetPosition sets current position, eg. to 0 after homing. The motor should be stopped.
+func (s *Stepper) SetPosition(position int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.profile.SetPosition(position)
+	s.nextTime = 0
+}
+
+// Speed returns current speed [steps/s], negative in backward direction
+func (s *Stepper) Speed() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.profile.Speed()
+}
+
+// Run makes a step if it is due, returns whether the motor is still moving. It never blocks,
+// call it as often as possible (more often than the shortest step interval).
+func (s *Stepper) Run() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if s.nextTime != 0 && now < s.nextTime {
+		return true
+	}
+
+	dir, interval, ok := s.profile.Next()
+	if !ok {
+		s.nextTime = 0
+		return false
+	}
+	s.output(dir)
+
+	if s.nextTime == 0 || now-s.nextTime > interval { // starting, or too late to keep the schedule
+		s.nextTime = now
+	}
+	s.nextTime += interval
+	return true
+}
+
+// RunToPosition moves the motor to its target, blocks until it stops
+func (s *Stepper) RunToPosition() {
+	for s.Run() {
+		s.mu.Lock()
+		wait := s.nextTime - s.now()
+		s.mu.Unlock()
+		if wait > 0 {
+			s.delay(uint32((wait + time.Microsecond - 1) / time.Microsecond)) // round up, the step is due after wait
+		}
+	}
+}
+
+// output makes single step in given direction
+func (s *Stepper) output(dir int) {
+	if s.coils != nil {
+		s.phase = (s.phase + dir + len(s.sequence)) % len(s.sequence)
+		s.writeCoils(s.sequence[s.phase])
+		return
+	}
+
+	if dir != s.lastDir {
+		level := High
+		if dir < 0 {
+			level = Low
+		}
+		s.dir.Write(level)
+		s.lastDir = dir
+		s.delay(1) // dir setup time
+	}
+	s.step.Write(High)
+	s.delay(2) // minimal pulse width, 1µs A4988, 1.9µs DRV8825
+	s.step.Write(Low)
+}
+
+func (s *Stepper) writeCoils(pattern uint8) {
+	for i, pin := range s.coils {
+		pin.Write(State(pattern >> uint(i) & 1))
+	}
+}
@@ -0,0 +1,286 @@
+package rpio
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+// stepperPin records writes to an output
+type stepperPin struct {
+	level  State
+	output bool
+	pulses int // rising edges
+}
+
+func (p *stepperPin) Input()      { p.output = false }
+func (p *stepperPin) Output()     { p.output = true }
+func (p *stepperPin) Read() State { return p.level }
+
+func (p *stepperPin) Write(s State) {
+	if s == High && p.level == Low {
+		p.pulses++
+	}
+	p.level = s
+}
+
+// runProfile steps the profile until it stops, returns directions and intervals of the steps
+func runProfile(t *testing.T, p *StepProfile, limit int) (dirs []int, intervals []time.Duration) {
+	for {
+		dir, interval, ok := p.Next()
+		if !ok {
+			return dirs, intervals
+		}
+		dirs = append(dirs, dir)
+		intervals = append(intervals, interval)
+		if len(dirs) > limit {
+			t.Fatalf("profile did not stop after %d steps", limit)
+		}
+	}
+}
+
+func TestStepProfileTrapezoidal(t *testing.T) {
+	p, _ := NewStepProfile(1000, 2000, ProfileTrapezoidal) // ramp of 250 steps
+	p.SetTarget(2000)
+	dirs, intervals := runProfile(t, p, 10000)
+
+	if len(dirs) != 2000 || p.Position() != 2000 || p.Moving() {
+		t.Fatalf("made %d steps to %d, moving %v", len(dirs), p.Position(), p.Moving())
+	}
+	for i, dir := range dirs {
+		if dir != 1 {
+			t.Fatalf("step %d in direction %d", i, dir)
+		}
+	}
+
+	var total time.Duration
+	for i, interval := range intervals {
+		if interval < time.Millisecond {
+			t.Fatalf("step %d: interval %v faster than max speed", i, interval)
+		}
+		if i > 0 && i < 1000 && interval > intervals[i-1] {
+			t.Fatalf("step %d: interval %v longer than previous %v while accelerating", i, interval, intervals[i-1])
+		}
+		total += interval
+	}
+	if intervals[1000] != time.Millisecond {
+		t.Errorf("cruise interval %v, want 1ms", intervals[1000])
+	}
+	if intervals[0] != intervals[len(intervals)-1] {
+		t.Errorf("first interval %v, last %v", intervals[0], intervals[len(intervals)-1])
+	}
+	// 1500 steps at full speed + 2 ramps of 0.5s
+	if total < 2400*time.Millisecond || total > 2600*time.Millisecond {
+		t.Errorf("move took %v, want 2.5s", total)
+	}
+}
+
+func TestStepProfileSCurve(t *testing.T) {
+	trapezoid, _ := NewStepProfile(1000, 2000, ProfileTrapezoidal)
+	scurve, _ := NewStepProfile(1000, 2000, ProfileSCurve)
+	trapezoid.SetTarget(2000)
+	scurve.SetTarget(2000)
+	_, ti := runProfile(t, trapezoid, 10000)
+	_, si := runProfile(t, scurve, 10000)
+
+	if len(si) != 2000 || scurve.Position() != 2000 {
+		t.Fatalf("made %d steps to %d", len(si), scurve.Position())
+	}
+	if si[0] <= ti[0] {
+		t.Errorf("S-curve first interval %v, not longer than trapezoidal %v", si[0], ti[0])
+	}
+	if si[1000] != time.Millisecond {
+		t.Errorf("cruise interval %v, want 1ms", si[1000])
+	}
+}
+
+func TestStepProfileRetarget(t *testing.T) {
+	p, _ := NewStepProfile(1000, 2000, ProfileTrapezoidal)
+	p.SetTarget(1000)
+	for i := 0; i < 300; i++ { // at full speed
+		p.Next()
+	}
+	p.SetTarget(0)
+	dirs, _ := runProfile(t, p, 10000)
+
+	// decelerate past 300, then return
+	peak, reversals := int64(300), 0
+	pos := int64(300)
+	for i, dir := range dirs {
+		if i > 0 && dir != dirs[i-1] {
+			reversals++
+		}
+		pos += int64(dir)
+		if pos > peak {
+			peak = pos
+		}
+	}
+	if p.Position() != 0 || reversals != 1 {
+		t.Errorf("position %d after %d reversals, want 0 after 1", p.Position(), reversals)
+	}
+	if peak != 550 {
+		t.Errorf("overshoot to %d, want 550", peak)
+	}
+
+	// stop while moving
+	p.SetTarget(1000)
+	for i := 0; i < 100; i++ {
+		p.Next()
+	}
+	p.Stop()
+	runProfile(t, p, 10000)
+	if p.Position() != 200 {
+		t.Errorf("stopped at %d, want 200", p.Position())
+	}
+
+	// short moves
+	for _, target := range []int64{201, 199, 199} {
+		p.SetTarget(target)
+		runProfile(t, p, 10000)
+		if p.Position() != target {
+			t.Errorf("moved to %d, want %d", p.Position(), target)
+		}
+	}
+}
+
+func TestStepProfileConstantSpeed(t *testing.T) {
+	p, err := NewStepProfile(500, 0, ProfileSCurve) // no ramps
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.SetTarget(-100)
+	dirs, intervals := runProfile(t, p, 1000)
+	if len(dirs) != 100 || p.Position() != -100 || p.Moving() {
+		t.Fatalf("made %d steps to %d, moving %v", len(dirs), p.Position(), p.Moving())
+	}
+	for i, interval := range intervals {
+		if dirs[i] != -1 || interval != 2*time.Millisecond {
+			t.Fatalf("step %d: direction %d, interval %v", i, dirs[i], interval)
+		}
+	}
+}
+
+func TestStepperConfig(t *testing.T) {
+	for _, config := range []StepperConfig{
+		{},                                  // zero value
+		{MaxSpeed: -100, Acceleration: 100}, // negative speed
+		{MaxSpeed: 100, Acceleration: -1},   // negative acceleration
+		{MaxSpeed: math.NaN(), Acceleration: 100},
+		{MaxSpeed: math.Inf(1), Acceleration: 100},
+	} {
+		if _, err := NewStepDirStepper(&stepperPin{}, &stepperPin{}, config); err != StepperConfigError {
+			t.Errorf("%+v: error %v, want %v", config, err, StepperConfigError)
+		}
+	}
+
+	p, _ := NewStepProfile(1000, 2000, ProfileTrapezoidal)
+	if err := p.SetSpeed(0, 2000); err != StepperConfigError {
+		t.Errorf("SetSpeed(0): error %v, want %v", err, StepperConfigError)
+	}
+	p.SetTarget(10) // previous speed is kept
+	if dirs, _ := runProfile(t, p, 100); len(dirs) != 10 {
+		t.Errorf("made %d steps after invalid speed", len(dirs))
+	}
+}
+
+func TestStepDirStepper(t *testing.T) {
+	step, dir, enable := &stepperPin{}, &stepperPin{}, &stepperPin{level: High}
+	ms := []DigitalPin{&stepperPin{}, &stepperPin{}, &stepperPin{}}
+	s, err := NewStepDirStepper(step, dir, StepperConfig{
+		MaxSpeed: 1000, Acceleration: 2000,
+		Enable: enable, EnableActive: Low, Microstep: ms,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now time.Duration
+	s.now = func() time.Duration { return now }
+	s.delay = func(us uint32) { now += time.Duration(us) * time.Microsecond }
+
+	if !step.output || !dir.output || !enable.output || enable.level != High {
+		t.Fatalf("pins not initialized")
+	}
+	s.Enable(true)
+	if enable.level != Low {
+		t.Errorf("enable pin %v, want Low", enable.level)
+	}
+
+	bits, err := DRV8825Microstep(16)
+	if err != nil || bits != 0b100 {
+		t.Fatalf("DRV8825 1/16: %03b %v", bits, err)
+	}
+	if _, err := A4988Microstep(32); err != StepperMicrostepError {
+		t.Errorf("A4988 1/32: error %v, want %v", err, StepperMicrostepError)
+	}
+	s.SetMicrostep(bits)
+	if ms[0].Read() != Low || ms[1].Read() != Low || ms[2].Read() != High {
+		t.Errorf("microstep pins not set to %03b", bits)
+	}
+
+	s.Move(-5)
+	if !s.Run() || step.pulses != 1 || dir.level != Low {
+		t.Fatalf("first step: %d pulses, dir %v", step.pulses, dir.level)
+	}
+	if !s.Run() || step.pulses != 1 {
+		t.Errorf("step made before its time")
+	}
+	s.RunToPosition()
+	if s.Position() != -5 || step.pulses != 5 || s.Run() {
+		t.Errorf("position %d after %d pulses", s.Position(), step.pulses)
+	}
+
+	s.MoveTo(0)
+	s.RunToPosition()
+	if s.Position() != 0 || step.pulses != 10 || dir.level != High {
+		t.Errorf("position %d after %d pulses, dir %v", s.Position(), step.pulses, dir.level)
+	}
+}
+
+func TestUnipolarStepper(t *testing.T) {
+	var coils [4]DigitalPin
+	for i := range coils {
+		coils[i] = &stepperPin{}
+	}
+	s, err := NewUnipolarStepper(coils, true, StepperConfig{MaxSpeed: 500, Acceleration: 1000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var now time.Duration
+	s.now = func() time.Duration { return now }
+	s.delay = func(us uint32) { now += time.Duration(us) * time.Microsecond }
+
+	pattern := func() (bits uint8) {
+		for i, pin := range coils {
+			bits |= uint8(pin.Read()) << uint(i)
+		}
+		return bits
+	}
+
+	s.Move(3)
+	var seen []uint8
+	for s.Run() {
+		if p := pattern(); len(seen) == 0 || seen[len(seen)-1] != p {
+			seen = append(seen, p)
+		}
+		now += time.Millisecond
+	}
+	want := []uint8{0b0011, 0b0010, 0b0110}
+	if len(seen) != len(want) {
+		t.Fatalf("coil patterns %04b, want %04b", seen, want)
+	}
+	for i := range want {
+		if seen[i] != want[i] {
+			t.Fatalf("coil patterns %04b, want %04b", seen, want)
+		}
+	}
+
+	s.Move(-4) // back across the start of the sequence
+	s.RunToPosition()
+	if p := pattern(); p != 0b1001 {
+		t.Errorf("coils %04b at position %d, want 1001", p, s.Position())
+	}
+	s.Enable(false)
+	if p := pattern(); p != 0 {
+		t.Errorf("coils %04b after disable", p)
+	}
+}
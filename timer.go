@@ -0,0 +1,115 @@
+package rpio
+
+import (
+	"sync"
+	"time"
+)
+
+// System timer registers
+const (
+	timerCsReg  = 0 // control/status, match flags of compare registers
+	timerCloReg = 1 // counter lower 32 bits
+	timerChiReg = 2 // counter higher 32 bits
+	timerC0Reg  = 3 // compare 0, C1 - C3 follow
+)
+
+var (
+	timerValid bool         // timer registers are mapped and counting
+	timerEpoch = time.Now() // fallback time source when timer is not mapped
+
+	sleepLatency     time.Duration
+	sleepLatencyOnce sync.Once
+)
+
+// checkTimer checks whether timer counts at 1MHz, it does not with /dev/gpiomem.
+// Also calibrates sleep latency used by DelayMicroseconds.
+func checkTimer() {
+	sleepLatencyOnce.Do(calibrateSleep)
+
+	timerValid = false
+	if !currentChip.Has(PeriphTimer) || timerMem == nil {
+		return
+	}
+	start := timerMem[timerCloReg]
+	time.Sleep(time.Millisecond)
+	elapsed := timerMem[timerCloReg] - start
+	timerValid = elapsed >= 900 && elapsed < 100000
+}
+
+// SystemTime returns value of the free running 64-bit system timer counter,
+// incremented every microsecond.
+//
+// If the timer is not available (Pi 5, or /dev/gpiomem used instead of /dev/mem)
+// microseconds elapsed since the program start are returned instead.
+func SystemTime() uint64 {
+	if !timerValid {
+		return uint64(time.Since(timerEpoch) / time.Microsecond)
+	}
+	for {
+		hi := timerMem[timerChiReg]
+		lo := timerMem[timerCloReg]
+		if timerMem[timerChiReg] == hi { // no overflow of lower part in between
+			return uint64(hi)<<32 | uint64(lo)
+		}
+	}
+}
+
+// DelayMicroseconds waits for given number of microseconds.
+//
+// Unlike time.Sleep, which usually sleeps tens of microseconds longer than requested,
+// it busy waits on the system timer. Long delays sleep first,
+// for the part which is safely shorter than the (calibrated) sleep latency.
+func DelayMicroseconds(us uint32) {
+	target := SystemTime() + uint64(us)
+
+	sleepLatencyOnce.Do(calibrateSleep)
+	if d := time.Duration(us)*time.Microsecond - 2*sleepLatency; d > 0 {
+		time.Sleep(d)
+	}
+
+	for SystemTime() < target {
+	}
+}
+
+// calibrateSleep measures how much longer than requested time.Sleep takes
+func calibrateSleep() {
+	const samples = 10
+	var worst time.Duration
+	for i := 0; i < samples; i++ {
+		start := time.Now()
+		time.Sleep(time.Microsecond)
+		if d := time.Since(start); d > worst {
+			worst = d
+		}
+	}
+	sleepLatency = worst
+}
+
+// TimerCompare sets compare register of given timer channel (0 - 3).
+// When the lower 32 bits of the counter match the value, the channel match flag is set.
+//
+// Note that channels 0 and 2 are used by the GPU, use channel 1 or 3.
+func TimerCompare(channel int, value uint32) {
+	if !timerValid || channel < 0 || channel > 3 {
+		return
+	}
+	timerMem[timerCsReg] = 1 << uint(channel) // clear previous match
+	timerMem[timerC0Reg+channel] = value
+}
+
+// TimerMatched checks whether counter matched compare register of given channel
+// since TimerCompare was called.
+func TimerMatched(channel int) bool {
+	if !timerValid || channel < 0 || channel > 3 {
+		return false
+	}
+	return timerMem[timerCsReg]&(1<<uint(channel)) != 0
+}
+
+// TimerClearMatch clears match flag of given channel
+func TimerClearMatch(channel int) {
+	if !timerValid || channel < 0 || channel > 3 {
+		return
+	}
+	timerMem[timerCsReg] = 1 << uint(channel)
+}
@@ -0,0 +1,27 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestDelayMicroseconds(t *testing.T) {
+	sleepLatencyOnce.Do(calibrateSleep) // done by Open
+
+	for _, us := range []uint32{1, 10, 100, 2000} {
+		start := SystemTime()
+		begin := time.Now()
+		DelayMicroseconds(us)
+		elapsed := SystemTime() - start
+
+		if elapsed < uint64(us) {
+			t.Errorf("%dµs: counter advanced only %dµs", us, elapsed)
+		}
+		// counter has microsecond resolution, so delay may be up to 1µs shorter,
+		// upper bound is not checked as the test can be preempted
+		want := time.Duration(us) * time.Microsecond
+		if d := time.Since(begin); d < want-time.Microsecond {
+			t.Errorf("%dµs: delay took %s", us, d)
+		}
+	}
+}
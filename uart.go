@@ -0,0 +1,274 @@
+package rpio
+
+import (
+	"errors"
+	"time"
+)
+
+type Parity uint8
+type FifoLevel uint8
+
+// Parity of uart frames
+const (
+	ParityNone Parity = iota
+	ParityOdd
+	ParityEven
+)
+
+// Fifo fill level thresholds
+const (
+	FifoEighth FifoLevel = iota
+	FifoQuarter
+	FifoHalf
+	FifoThreeQuarters
+	FifoSevenEighths
+)
+
+// PL011 registers
+const (
+	uartDrReg   = 0x00 / 4 // data
+	uartFrReg   = 0x18 / 4 // flags
+	uartIbrdReg = 0x24 / 4 // integer baud rate divisor
+	uartFbrdReg = 0x28 / 4 // fractional baud rate divisor
+	uartLcrhReg = 0x2c / 4 // line control
+	uartCrReg   = 0x30 / 4 // control
+	uartIflsReg = 0x34 / 4 // fifo level select
+	uartImscReg = 0x38 / 4 // interrupt mask
+	uartIcrReg  = 0x44 / 4 // interrupt clear
+	uartIDReg   = 0xfe0 / 4
+)
+
+// PL011 flag register bits
+const (
+	uartBusy = 1 << 3
+	uartRxfe = 1 << 4 // rx fifo empty
+	uartTxff = 1 << 5 // tx fifo full
+)
+
+// PL011 data register error bits
+const (
+	uartFe = 1 << 8  // framing error
+	uartPe = 1 << 9  // parity error
+	uartBe = 1 << 10 // break
+	uartOe = 1 << 11 // overrun
+)
+
+var (
+	UartMapError         = errors.New("UART registers not mapped correctly - are you root?")
+	UartUnsupportedError = errors.New("UART is not supported on this chip")
+	UartConfigError      = errors.New("invalid UART configuration")
+	UartFramingError     = errors.New("UART framing error")
+	UartParityError      = errors.New("UART parity error")
+	UartBreakError       = errors.New("UART break condition")
+	UartOverrunError     = errors.New("UART receive overrun")
+
+	TimeoutError = errors.New("rpio: timeout")
+)
+
+// UartConfig: Settings of Uart, zero values of DataBits and StopBits mean 8 and 1
+type UartConfig struct {
+	BaudRate    int
+	DataBits    int // 5 - 8
+	Parity      Parity
+	StopBits    int  // 1 or 2
+	FlowControl bool // RTS/CTS on pins 17/16
+
+	TxFifoLevel FifoLevel
+	RxFifoLevel FifoLevel
+
+	// Read waits at most ReadTimeout for first byte to arrive,
+	// zero means wait forever
+	ReadTimeout time.Duration
+
+	// Write waits at most WriteTimeout for space in tx fifo (eg. while CTS is inactive),
+	// zero means wait forever
+	WriteTimeout time.Duration
+}
+
+// Uart is the PL011 UART0, implementing io.ReadWriteCloser
+type Uart struct {
+	config UartConfig
+}
+
+// UartBegin: Sets pins 14 (TxD) and 15 (RxD) to UART0 mode, and 16 (CTS), 17 (RTS)
+// if flow control is enabled, and configures the uart.
+//
+// Note that the serial console should be disabled in raspi-config first.
+// On Pi 3 and 4 the PL011 is used by bluetooth by default,
+// add `dtoverlay=disable-bt` to `/boot/config.txt` to free it.
+func UartBegin(config UartConfig) (*Uart, error) {
+	if !currentChip.Has(PeriphUart) {
+		return nil, UartUnsupportedError
+	}
+
+	if config.DataBits == 0 {
+		config.DataBits = 8
+	}
+	if config.StopBits == 0 {
+		config.StopBits = 1
+	}
+	if config.BaudRate <= 0 ||
+		config.DataBits < 5 || config.DataBits > 8 ||
+		config.StopBits < 1 || config.StopBits > 2 ||
+		config.Parity > ParityEven ||
+		config.TxFifoLevel > FifoSevenEighths || config.RxFifoLevel > FifoSevenEighths {
+		return nil, UartConfigError
+	}
+
+	ibrd, fbrd := uartDivisor(currentChip.UartFreq, config.BaudRate)
+	if ibrd == 0 || ibrd > 0xffff {
+		return nil, UartConfigError
+	}
+
+	if uartMem[uartIDReg]&0xff != 0x11 {
+		// peripheral id does not match PL011 -> mem map failed
+		return nil, UartMapError
+	}
+
+	const uarten = 1 << 0
+	const txe = 1 << 8
+	const rxe = 1 << 9
+	const rtsen = 1 << 14
+	const ctsen = 1 << 15
+
+	// disable uart and wait for end of transmission
+	uartMem[uartCrReg] = 0
+	for uartMem[uartFrReg]&uartBusy != 0 {
+		time.Sleep(time.Microsecond * 10)
+	}
+	uartMem[uartLcrhReg] = 0 // flush fifos
+
+	Pin(14).Mode(Alt0)
+	Pin(15).Mode(Alt0)
+	if config.FlowControl {
+		Pin(16).Mode(Alt3)
+		Pin(17).Mode(Alt3)
+	}
+
+	uartMem[uartIbrdReg] = ibrd
+	uartMem[uartFbrdReg] = fbrd
+	uartMem[uartLcrhReg] = uartLineControl(config) // writing lcrh latches the divisors
+	uartMem[uartIflsReg] = uint32(config.RxFifoLevel)<<3 | uint32(config.TxFifoLevel)
+	uartMem[uartImscReg] = 0    // polling, no interrupts
+	uartMem[uartIcrReg] = 0x7ff // clear pending interrupts
+
+	cr := uint32(uarten | txe | rxe)
+	if config.FlowControl {
+		cr |= rtsen | ctsen
+	}
+	uartMem[uartCrReg] = cr
+
+	return &Uart{config: config}, nil
+}
+
+// Read reads available bytes into p, waiting up to ReadTimeout for the first one.
+//
+// Returns TimeoutError if nothing was received,
+// or one of UartFramingError, UartParityError, UartBreakError or UartOverrunError
+// if the received byte was corrupted.
+func (u *Uart) Read(p []byte) (n int, err error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	deadline := time.Now().Add(u.config.ReadTimeout)
+	for uartMem[uartFrReg]&uartRxfe != 0 {
+		if u.config.ReadTimeout > 0 && time.Now().After(deadline) {
+			return 0, TimeoutError
+		}
+		time.Sleep(time.Microsecond * 100)
+	}
+
+	for n < len(p) && uartMem[uartFrReg]&uartRxfe == 0 {
+		data := uartMem[uartDrReg]
+		switch {
+		case data&uartOe != 0:
+			return n, UartOverrunError
+		case data&uartBe != 0:
+			return n, UartBreakError
+		case data&uartPe != 0:
+			return n, UartParityError
+		case data&uartFe != 0:
+			return n, UartFramingError
+		}
+		p[n] = byte(data)
+		n++
+	}
+	return n, nil
+}
+
+// Write sends all bytes of p, blocks while tx fifo is full.
+//
+// Returns TimeoutError and number of bytes written if tx fifo stays full for WriteTimeout.
+func (u *Uart) Write(p []byte) (n int, err error) {
+	for _, b := range p {
+		if !u.waitTx() {
+			return n, TimeoutError
+		}
+		uartMem[uartDrReg] = uint32(b)
+		n++
+	}
+	return n, nil
+}
+
+// waitTx waits up to WriteTimeout until tx fifo is not full
+func (u *Uart) waitTx() bool {
+	if uartMem[uartFrReg]&uartTxff == 0 {
+		return true
+	}
+	deadline := time.Now().Add(u.config.WriteTimeout)
+	for uartMem[uartFrReg]&uartTxff != 0 {
+		if u.config.WriteTimeout > 0 && time.Now().After(deadline) {
+			return false
+		}
+	}
+	return true
+}
+
+// Flush waits until all bytes written are transmitted
+func (u *Uart) Flush() {
+	for uartMem[uartFrReg]&uartBusy != 0 {
+		time.Sleep(time.Microsecond * 10)
+	}
+}
+
+// Close: Waits for end of transmission, disables the uart
+// and sets its pins to default (Input) mode. See UartBegin.
+func (u *Uart) Close() error {
+	u.Flush()
+	uartMem[uartCrReg] = 0
+
+	Pin(14).Mode(Input)
+	Pin(15).Mode(Input)
+	if u.config.FlowControl {
+		Pin(16).Mode(Input)
+		Pin(17).Mode(Input)
+	}
+	return nil
+}
+
+// uartDivisor computes integer and fractional (in 1/64) part of baud rate divisor clk / (16 * baud)
+func uartDivisor(clk, baud int) (ibrd, fbrd uint32) {
+	div := (uint64(clk)*4 + uint64(baud)/2) / uint64(baud) // in 1/64
+	return uint32(div >> 6), uint32(div & 63)
+}
+
+// uartLineControl computes value of line control register
+func uartLineControl(config UartConfig) uint32 {
+	const pen = 1 << 1  // parity enable
+	const eps = 1 << 2  // even parity
+	const stp2 = 1 << 3 // two stop bits
+	const fen = 1 << 4  // fifo enable
+
+	lcrh := uint32(fen) | uint32(config.DataBits-5)<<5
+	switch config.Parity {
+	case ParityOdd:
+		lcrh |= pen
+	case ParityEven:
+		lcrh |= pen | eps
+	}
+	if config.StopBits == 2 {
+		lcrh |= stp2
+	}
+	return lcrh
+}
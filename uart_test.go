@@ -0,0 +1,69 @@
+package rpio
+
+import "testing"
+
+func TestUartDivisor(t *testing.T) {
+	tests := []struct {
+		clk, baud  int
+		ibrd, fbrd uint32
+	}{
+		{48000000, 115200, 26, 3},
+		{48000000, 9600, 312, 32},
+		{3000000, 9600, 19, 34},
+		{3000000, 115200, 1, 40},
+	}
+	for _, test := range tests {
+		ibrd, fbrd := uartDivisor(test.clk, test.baud)
+		if ibrd != test.ibrd || fbrd != test.fbrd {
+			t.Errorf("%d/%d: got %d.%d, want %d.%d", test.clk, test.baud, ibrd, fbrd, test.ibrd, test.fbrd)
+		}
+	}
+}
+
+func TestUartLineControl(t *testing.T) {
+	tests := []struct {
+		config UartConfig
+		lcrh   uint32
+	}{
+		{UartConfig{DataBits: 8, StopBits: 1}, 0x70},
+		{UartConfig{DataBits: 7, Parity: ParityEven, StopBits: 1}, 0x56},
+		{UartConfig{DataBits: 5, Parity: ParityOdd, StopBits: 2}, 0x1a},
+	}
+	for _, test := range tests {
+		if lcrh := uartLineControl(test.config); lcrh != test.lcrh {
+			t.Errorf("%+v: got %#x, want %#x", test.config, lcrh, test.lcrh)
+		}
+	}
+}
+
+func TestUartConfig(t *testing.T) {
+	for _, config := range []UartConfig{
+		{BaudRate: 0},
+		{BaudRate: 9600, DataBits: 9},
+		{BaudRate: 9600, StopBits: -1},
+		{BaudRate: 9600, StopBits: 3},
+		{BaudRate: 9600, Parity: ParityEven + 1},
+		{BaudRate: 10}, // divisor out of range
+	} {
+		if _, err := UartBegin(config); err != UartConfigError {
+			t.Errorf("%+v: error %v, want %v", config, err, UartConfigError)
+		}
+	}
+}
+
+func TestMiniUartDivisor(t *testing.T) {
+	tests := []struct {
+		clk, baud int
+		reg       uint32
+	}{
+		{250000000, 115200, 270},
+		{250000000, 9600, 3254},
+		{400000000, 115200, 433},
+		{500000000, 115200, 542},
+	}
+	for _, test := range tests {
+		if reg := miniUartDivisor(test.clk, test.baud); reg != test.reg {
+			t.Errorf("%d/%d: got %d, want %d", test.clk, test.baud, reg, test.reg)
+		}
+	}
+}
@@ -0,0 +1,320 @@
+package rpio
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	DmaUnsupportedError = errors.New("DMA is not supported on this chip")
+	DmaMapError         = errors.New("DMA registers are not mapped, /dev/mem is required")
+	WavePinError        = errors.New("wave supports only pins 0 - 31")
+	WaveEmptyError      = errors.New("wave is empty")
+	WaveConfigError     = errors.New("invalid wave configuration")
+)
+
+// WavePacer selects peripheral whose fifo paces delays of a wave
+type WavePacer uint8
+
+const (
+	PacerPwm WavePacer = iota // PWM is unusable for other purposes while transmitting
+	PacerPcm                  // PCM is unusable for other purposes while transmitting
+)
+
+// DREQ (data request) peripheral numbers
+const (
+	dreqPcmTx = 2
+	dreqPwm   = 5
+)
+
+// Clock manager registers of PWM clock
+const (
+	pwmClkCtlReg = 40
+	pwmClkDivReg = 41
+)
+
+// Maximal delay of one control block, DMA lite channels transfer at most 65535 bytes
+const waveMaxBlockDelay = 0xffff / 4
+
+// waveStep sets and clears pins at once, then waits
+type waveStep struct {
+	set, clear uint32 // pin masks
+	delay      uint32 // [µs]
+}
+
+// Wave is a sequence of pin changes and delays, built like:
+//
+//	wave := rpio.NewWave().
+//	    Set(17).Delay(10).
+//	    Clear(17).Set(18).Delay(500).
+//	    Clear(18).Delay(1000)
+//
+// Pin changes without a delay in between are applied at once.
+// Only pins 0 - 31 are supported and they have to be in Output mode.
+type Wave struct {
+	steps []waveStep
+	err   error
+}
+
+// NewWave creates empty wave
+func NewWave() *Wave {
+	return &Wave{}
+}
+
+// current returns step which pin changes are added to
+func (w *Wave) current() *waveStep {
+	if len(w.steps) == 0 || w.steps[len(w.steps)-1].delay > 0 {
+		w.steps = append(w.steps, waveStep{})
+	}
+	return &w.steps[len(w.steps)-1]
+}
+
+// mask returns bit mask of pins, records error for unsupported pins
+func (w *Wave) mask(pins []Pin) uint32 {
+	var mask uint32
+	for _, pin := range pins {
+		if pin > 31 {
+			w.err = WavePinError
+			continue
+		}
+		mask |= 1 << pin
+	}
+	return mask
+}
+
+// Set adds setting of pins to High
+func (w *Wave) Set(pins ...Pin) *Wave {
+	mask := w.mask(pins)
+	step := w.current()
+	step.set |= mask
+	step.clear &^= mask
+	return w
+}
+
+// Clear adds setting of pins to Low
+func (w *Wave) Clear(pins ...Pin) *Wave {
+	mask := w.mask(pins)
+	step := w.current()
+	step.clear |= mask
+	step.set &^= mask
+	return w
+}
+
+// Delay adds waiting for given number of microseconds
+func (w *Wave) Delay(us uint32) *Wave {
+	if len(w.steps) == 0 {
+		w.steps = append(w.steps, waveStep{})
+	}
+	w.steps[len(w.steps)-1].delay += us
+	return w
+}
+
+// Duration returns total length of delays of the wave
+func (w *Wave) Duration() time.Duration {
+	var us uint64
+	for _, step := range w.steps {
+		us += uint64(step.delay)
+	}
+	return time.Duration(us) * time.Microsecond
+}
+
+// Err returns error recorded while building the wave
+func (w *Wave) Err() error {
+	return w.err
+}
+
+// CompileWaves translates chained waves to DMA control blocks, to be placed at given bus address.
+//
+// Each step of a wave writes its pin masks to GPSET0 and GPCLR0 registers, then its delay is produced
+// by writing one word per microsecond to fifo of the pacer peripheral, which accepts words only at that rate.
+// The last control block stops the transfer, or links back to the first one if repeat is set.
+func CompileWaves(base uint32, pacer WavePacer, repeat bool, waves ...*Wave) ([]DmaControlBlock, error) {
+	var fifo, dreq uint32
+	switch pacer {
+	case PacerPwm:
+		fifo, dreq = busPwmFifo, dreqPwm
+	case PacerPcm:
+		fifo, dreq = busPcmFifo, dreqPcmTx
+	default:
+		return nil, WaveConfigError
+	}
+
+	var cbs []DmaControlBlock
+	add := func(cb DmaControlBlock) {
+		addr := base + uint32(len(cbs))*dmaControlBlockSize
+		cb.TransferInfo |= dmaNoWideBursts | dmaWaitResp
+		cb.Source = addr + 24 // data word of the block itself
+		cb.Length = 4 * cb.Length
+		cb.Next = addr + dmaControlBlockSize
+		cbs = append(cbs, cb)
+	}
+
+	var delay uint32
+	for _, w := range waves {
+		if w.err != nil {
+			return nil, w.err
+		}
+		for _, step := range w.steps {
+			if step.set != 0 {
+				add(DmaControlBlock{Dest: busGpset0, Length: 1, Data: [2]uint32{step.set}})
+			}
+			if step.clear != 0 {
+				add(DmaControlBlock{Dest: busGpclr0, Length: 1, Data: [2]uint32{step.clear}})
+			}
+			for d := step.delay; d > 0; {
+				n := d
+				if n > waveMaxBlockDelay {
+					n = waveMaxBlockDelay
+				}
+				add(DmaControlBlock{TransferInfo: dmaDestDreq | dmaPermap(dreq), Dest: fifo, Length: n})
+				d -= n
+			}
+			delay += step.delay
+		}
+	}
+
+	if len(cbs) == 0 || (repeat && delay == 0) {
+		return nil, WaveEmptyError
+	}
+
+	if repeat {
+		cbs[len(cbs)-1].Next = base
+	} else {
+		cbs[len(cbs)-1].Next = 0
+	}
+	return cbs, nil
+}
+
+// WaveConfig: Settings of wave transmission, zero value of Channel selects DMA channel 10 (channel 0 cannot be used)
+type WaveConfig struct {
+	Channel int // DMA channel 1 - 10, which is not used by the system (DMA4 channels 11 - 14 of BCM2711 are not supported)
+	Pacer   WavePacer
+	Repeat  bool // repeat waves until stopped
+}
+
+// WaveTransmission is a running transmission of waves, see WaveTransmit
+type WaveTransmission struct {
+	config WaveConfig
+	mem    *dmaMemory
+}
+
+// WaveTransmit: Starts transmitting chained waves in background, driven by DMA.
+//
+// Timing of the waves does not depend on scheduling of the program, the resolution is 1µs.
+// Note that the pacer fifo accepts several words at once when the transmission starts,
+// so the first delay is shortened (PWM) or the start is delayed (PCM) by a few microseconds.
+//
+// Requires /dev/mem and the firmware mailbox (/dev/vcio) for allocation of uncached memory.
+// Call Stop to release the resources, also when the transmission ended.
+func WaveTransmit(config WaveConfig, waves ...*Wave) (*WaveTransmission, error) {
+	if !currentChip.Has(PeriphDma) {
+		return nil, DmaUnsupportedError
+	}
+	if !devMem { // /dev/gpiomem maps GPIO registers to all the slices
+		return nil, DmaMapError
+	}
+	if config.Channel == 0 {
+		config.Channel = 10
+	}
+	if config.Channel < 1 || config.Channel > 10 {
+		return nil, WaveConfigError
+	}
+
+	// compile once to know the size, then again with actual address
+	cbs, err := CompileWaves(0, config.Pacer, config.Repeat, waves...)
+	if err != nil {
+		return nil, err
+	}
+	mem, err := allocDmaMemory(len(cbs) * dmaControlBlockSize)
+	if err != nil {
+		return nil, err
+	}
+	cbs, _ = CompileWaves(mem.busAddr, config.Pacer, config.Repeat, waves...)
+	mem.writeControlBlocks(cbs)
+
+	t := &WaveTransmission{config: config, mem: mem}
+	t.startPacer()
+	dmaStart(config.Channel, mem.busAddr)
+	return t, nil
+}
+
+// Busy checks whether the waves are still being transmitted
+func (t *WaveTransmission) Busy() bool {
+	return t.mem != nil && dmaActiveChannel(t.config.Channel)
+}
+
+// Wait waits until transmission of the waves ends, never returns for repeated waves
+func (t *WaveTransmission) Wait() {
+	for t.Busy() {
+		time.Sleep(time.Millisecond)
+	}
+}
+
+// Stop: Aborts the transmission, stops the pacer and releases memory of control blocks.
+// Pins are left at their current level.
+func (t *WaveTransmission) Stop() error {
+	if t.mem == nil {
+		return nil
+	}
+	dmaStop(t.config.Channel)
+	t.stopPacer()
+	t.mem.free()
+	t.mem = nil
+	return nil
+}
+
+// startPacer sets pacer clock to 10MHz and configures the peripheral to consume one fifo word per 10 clocks
+func (t *WaveTransmission) startPacer() {
+	const pacerFreq = 10000000
+
+	switch t.config.Pacer {
+	case PacerPwm:
+		const (
+			pwmCtlReg  = 0
+			pwmDmacReg = 2
+			pwmRng1Reg = 4
+		)
+		const (
+			pwen1 = 1 << 0
+			mode1 = 1 << 1 // serializer
+			usef1 = 1 << 5
+			clrf1 = 1 << 6
+			enab  = 1 << 31
+		)
+
+		pwmMem[pwmCtlReg] = 0
+		DelayMicroseconds(10)
+		setClockSource(pwmClkCtlReg, pwmClkDivReg, clkSrcPllD, currentChip.PllDFreq, pacerFreq)
+		pwmMem[pwmRng1Reg] = 10                // bits per word
+		pwmMem[pwmDmacReg] = enab | 15<<8 | 15 // panic and dreq thresholds
+		pwmMem[pwmCtlReg] = clrf1
+		DelayMicroseconds(10)
+		pwmMem[pwmCtlReg] = usef1 | mode1 | pwen1
+
+	case PacerPcm:
+		const pcmDreqReg = 5
+		const pcmDmaEn = 1 << 9
+
+		pcmMem[pcmCsReg] = 0
+		DelayMicroseconds(10)
+		setClockSource(pcmClkCtlReg, pcmClkDivReg, clkSrcPllD, currentChip.PllDFreq, pacerFreq)
+		pcmMem[pcmCsReg] = pcmEn
+		pcmMem[pcmModeReg] = (10 - 1) << 10 // frame length 10 bit clocks
+		pcmMem[pcmTxcReg] = 1 << 30         // channel 1 enabled, 8 bits wide
+		pcmMem[pcmDreqReg] = 16<<24 | 30<<8 // tx panic and dreq thresholds
+		pcmMem[pcmCsReg] |= pcmTxClr
+		DelayMicroseconds(10)
+		pcmMem[pcmCsReg] |= pcmDmaEn | pcmTxOn
+	}
+}
+
+// stopPacer disables the pacer peripheral
+func (t *WaveTransmission) stopPacer() {
+	switch t.config.Pacer {
+	case PacerPwm:
+		pwmMem[0] = 0 // ctl
+		pwmMem[2] = 0 // dmac
+	case PacerPcm:
+		pcmMem[pcmCsReg] = 0
+	}
+}
@@ -0,0 +1,72 @@
+package rpio
+
+import (
+	"testing"
+	"time"
+)
+
+func TestWaveBuilder(t *testing.T) {
+	w := NewWave().Set(17, 18).Clear(18).Delay(10).Delay(5).Clear(17).Delay(20)
+
+	want := []waveStep{
+		{set: 1 << 17, clear: 1 << 18, delay: 15},
+		{clear: 1 << 17, delay: 20},
+	}
+	if len(w.steps) != len(want) {
+		t.Fatalf("steps %+v, want %+v", w.steps, want)
+	}
+	for i := range want {
+		if w.steps[i] != want[i] {
+			t.Errorf("step %d: %+v, want %+v", i, w.steps[i], want[i])
+		}
+	}
+	if d := w.Duration(); d != 35*time.Microsecond {
+		t.Errorf("duration %v, want 35µs", d)
+	}
+
+	if err := NewWave().Set(40).Err(); err != WavePinError {
+		t.Errorf("pin 40: error %v, want %v", err, WavePinError)
+	}
+}
+
+func TestCompileWaves(t *testing.T) {
+	const base = 0xc0001000
+	const ti = dmaNoWideBursts | dmaWaitResp
+	const paced = ti | dmaDestDreq | dreqPwm<<16
+
+	first := NewWave().Set(4).Delay(100)
+	second := NewWave().Clear(4).Set(5).Delay(waveMaxBlockDelay + 1)
+
+	cbs, err := CompileWaves(base, PacerPwm, false, first, second)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []DmaControlBlock{
+		{ti, base + 0x18, busGpset0, 4, 0, base + 0x20, [2]uint32{1 << 4}},
+		{paced, base + 0x38, busPwmFifo, 400, 0, base + 0x40, [2]uint32{}},
+		{ti, base + 0x58, busGpset0, 4, 0, base + 0x60, [2]uint32{1 << 5}},
+		{ti, base + 0x78, busGpclr0, 4, 0, base + 0x80, [2]uint32{1 << 4}},
+		{paced, base + 0x98, busPwmFifo, waveMaxBlockDelay * 4, 0, base + 0xa0, [2]uint32{}},
+		{paced, base + 0xb8, busPwmFifo, 4, 0, 0, [2]uint32{}},
+	}
+	if len(cbs) != len(want) {
+		t.Fatalf("%d control blocks, want %d", len(cbs), len(want))
+	}
+	for i := range want {
+		if cbs[i] != want[i] {
+			t.Errorf("control block %d: %+v, want %+v", i, cbs[i], want[i])
+		}
+	}
+
+	cbs, err = CompileWaves(base, PacerPcm, true, first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if last := cbs[len(cbs)-1]; last.Next != base || last.Dest != busPcmFifo || last.TransferInfo&(0x1f<<16) != dreqPcmTx<<16 {
+		t.Errorf("repeated pcm wave: last control block %+v", last)
+	}
+
+	if _, err := CompileWaves(base, PacerPwm, true, NewWave().Set(4)); err != WaveEmptyError {
+		t.Errorf("repeat without delay: error %v, want %v", err, WaveEmptyError)
+	}
+}